@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ynab-alerts/internal/rules"
+)
+
+// Output formats for the lint command.
+const (
+	lintFormatText   = "text"
+	lintFormatJSON   = "json"
+	lintFormatSARIF  = "sarif"
+	lintFormatGitHub = "github"
+)
+
+// writeLint prints lint results in format. Positions are reported relative to
+// the working directory by joining them onto rulesDir.
+func writeLint(w io.Writer, format, rulesDir string, results []rules.LintResult) error {
+	path := func(p rules.Position) string {
+		if p.File == "" {
+			return ""
+		}
+		return filepath.ToSlash(filepath.Join(rulesDir, p.File))
+	}
+	switch format {
+	case "", lintFormatText:
+		writeLintText(w, results, path)
+		return nil
+	case lintFormatJSON:
+		return writeLintJSON(w, results, path)
+	case lintFormatSARIF:
+		return writeLintSARIF(w, results, path)
+	case lintFormatGitHub:
+		writeLintGitHub(w, results, path)
+		return nil
+	default:
+		return fmt.Errorf("unknown lint format %q (text|json|sarif|github)", format)
+	}
+}
+
+func writeLintText(w io.Writer, results []rules.LintResult, path func(rules.Position) string) {
+	for _, r := range results {
+		next := "unknown"
+		if r.HasNext {
+			next = r.NextEval.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s:\n", r.Name)
+		if loc := path(r.Pos); loc != "" {
+			fmt.Fprintf(w, "  file: %s:%d\n", loc, r.Pos.Line)
+		}
+		if r.Severity != "" {
+			fmt.Fprintf(w, "  severity: %s\n  next: %s\n", r.Severity, next)
+		}
+		if len(r.Diagnostics) == 0 {
+			fmt.Fprintln(w, "  issues: none")
+			continue
+		}
+		fmt.Fprintln(w, "  issues:")
+		for _, d := range r.Diagnostics {
+			if loc := path(d.Pos); loc != "" && d.Pos.Line > 0 {
+				fmt.Fprintf(w, "    - %s: %s (%s:%d:%d)\n", d.Level, d.Message, loc, d.Pos.Line, d.Pos.Column)
+			} else {
+				fmt.Fprintf(w, "    - %s: %s\n", d.Level, d.Message)
+			}
+		}
+	}
+}
+
+type lintJSONDiagnostic struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Rule    string `json:"rule"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+type lintJSONRule struct {
+	Name        string               `json:"name"`
+	Severity    string               `json:"severity"`
+	File        string               `json:"file,omitempty"`
+	Line        int                  `json:"line,omitempty"`
+	NextEval    *time.Time           `json:"next_eval,omitempty"`
+	Diagnostics []lintJSONDiagnostic `json:"diagnostics"`
+}
+
+func writeLintJSON(w io.Writer, results []rules.LintResult, path func(rules.Position) string) error {
+	out := make([]lintJSONRule, 0, len(results))
+	for _, r := range results {
+		jr := lintJSONRule{Name: r.Name, Severity: r.Severity, File: path(r.Pos), Line: r.Pos.Line, Diagnostics: []lintJSONDiagnostic{}}
+		if r.HasNext {
+			next := r.NextEval
+			jr.NextEval = &next
+		}
+		for _, d := range r.Diagnostics {
+			jr.Diagnostics = append(jr.Diagnostics, lintJSONDiagnostic{
+				File:    path(d.Pos),
+				Line:    d.Pos.Line,
+				Column:  d.Pos.Column,
+				Rule:    r.Name,
+				Level:   d.Level,
+				Message: d.Message,
+			})
+		}
+		out = append(out, jr)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// sarifRuleID is the single analysis rule lint findings are reported under.
+const sarifRuleID = "ynab-alerts/lint"
+
+// writeLintSARIF writes a minimal SARIF 2.1.0 log, e.g. for GitHub code
+// scanning uploads.
+func writeLintSARIF(w io.Writer, results []rules.LintResult, path func(rules.Position) string) error {
+	type region struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn,omitempty"`
+	}
+	type location struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region *region `json:"region,omitempty"`
+		} `json:"physicalLocation"`
+	}
+	type message struct {
+		Text string `json:"text"`
+	}
+	type result struct {
+		RuleID    string     `json:"ruleId"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations,omitempty"`
+	}
+	findings := []result{}
+	for _, r := range results {
+		for _, d := range r.Diagnostics {
+			res := result{
+				RuleID:  sarifRuleID,
+				Level:   d.Level, // "error" and "warning" are SARIF levels too
+				Message: message{Text: fmt.Sprintf("%s: %s", r.Name, d.Message)},
+			}
+			if file := path(d.Pos); file != "" {
+				var loc location
+				loc.PhysicalLocation.ArtifactLocation.URI = file
+				if d.Pos.Line > 0 {
+					loc.PhysicalLocation.Region = &region{StartLine: d.Pos.Line, StartColumn: d.Pos.Column}
+				}
+				res.Locations = []location{loc}
+			}
+			findings = append(findings, res)
+		}
+	}
+	sarif := map[string]interface{}{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []interface{}{map[string]interface{}{
+			"tool": map[string]interface{}{"driver": map[string]interface{}{
+				"name":  "ynab-alerts",
+				"rules": []interface{}{map[string]interface{}{"id": sarifRuleID, "shortDescription": map[string]string{"text": "Rule file lint"}}},
+			}},
+			"results": findings,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarif)
+}
+
+// writeLintGitHub writes GitHub Actions workflow commands, which annotate the
+// offending lines in pull requests.
+func writeLintGitHub(w io.Writer, results []rules.LintResult, path func(rules.Position) string) {
+	for _, r := range results {
+		for _, d := range r.Diagnostics {
+			var props []string
+			if file := path(d.Pos); file != "" {
+				props = append(props, "file="+githubProperty(file))
+				if d.Pos.Line > 0 {
+					props = append(props, fmt.Sprintf("line=%d", d.Pos.Line), fmt.Sprintf("col=%d", d.Pos.Column))
+				}
+			}
+			props = append(props, "title="+githubProperty(r.Name))
+			fmt.Fprintf(w, "::%s %s::%s\n", d.Level, strings.Join(props, ","), githubData(d.Message))
+		}
+	}
+}
+
+func githubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func githubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
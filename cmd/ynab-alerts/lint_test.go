@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"ynab-alerts/internal/rules"
+)
+
+func lintFixture() []rules.LintResult {
+	return []rules.LintResult{
+		{
+			Name:     "low_checking",
+			Severity: "critical",
+			Pos:      rules.Position{File: "alerts.yaml", Line: 2, Column: 3},
+			Issues:   []string{`condition references unknown account "Chekcing"`, "schedule present; day/week gates will be ignored"},
+			Diagnostics: []rules.Diagnostic{
+				{Level: rules.LintError, Message: `condition references unknown account "Chekcing"`, Pos: rules.Position{File: "alerts.yaml", Line: 6, Column: 16}},
+				{Level: rules.LintWarning, Message: "schedule present; day/week gates will be ignored", Pos: rules.Position{File: "alerts.yaml", Line: 4, Column: 5}},
+			},
+		},
+		{
+			Name:     "payday",
+			Severity: "info",
+			Pos:      rules.Position{File: "sub/payday.yaml", Line: 1, Column: 3},
+			NextEval: time.Date(2024, time.March, 14, 9, 0, 0, 0, time.UTC),
+			HasNext:  true,
+		},
+		{
+			Name:        "load",
+			Issues:      []string{"yaml: line 3: mapping values are not allowed in this context"},
+			Diagnostics: []rules.Diagnostic{{Level: rules.LintError, Message: "yaml: line 3: mapping values are not allowed in this context"}},
+		},
+	}
+}
+
+func TestWriteLint(t *testing.T) {
+	cases := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: lintFormatText,
+			want: `low_checking:
+  file: rules/alerts.yaml:2
+  severity: critical
+  next: unknown
+  issues:
+    - error: condition references unknown account "Chekcing" (rules/alerts.yaml:6:16)
+    - warning: schedule present; day/week gates will be ignored (rules/alerts.yaml:4:5)
+payday:
+  file: rules/sub/payday.yaml:1
+  severity: info
+  next: 2024-03-14T09:00:00Z
+  issues: none
+load:
+  issues:
+    - error: yaml: line 3: mapping values are not allowed in this context
+`,
+		},
+		{
+			format: lintFormatJSON,
+			want: `[
+  {
+    "name": "low_checking",
+    "severity": "critical",
+    "file": "rules/alerts.yaml",
+    "line": 2,
+    "diagnostics": [
+      {
+        "file": "rules/alerts.yaml",
+        "line": 6,
+        "column": 16,
+        "rule": "low_checking",
+        "level": "error",
+        "message": "condition references unknown account \"Chekcing\""
+      },
+      {
+        "file": "rules/alerts.yaml",
+        "line": 4,
+        "column": 5,
+        "rule": "low_checking",
+        "level": "warning",
+        "message": "schedule present; day/week gates will be ignored"
+      }
+    ]
+  },
+  {
+    "name": "payday",
+    "severity": "info",
+    "file": "rules/sub/payday.yaml",
+    "line": 1,
+    "next_eval": "2024-03-14T09:00:00Z",
+    "diagnostics": []
+  },
+  {
+    "name": "load",
+    "severity": "",
+    "diagnostics": [
+      {
+        "rule": "load",
+        "level": "error",
+        "message": "yaml: line 3: mapping values are not allowed in this context"
+      }
+    ]
+  }
+]
+`,
+		},
+		{
+			format: lintFormatGitHub,
+			want: `::error file=rules/alerts.yaml,line=6,col=16,title=low_checking::condition references unknown account "Chekcing"
+::warning file=rules/alerts.yaml,line=4,col=5,title=low_checking::schedule present; day/week gates will be ignored
+::error title=load::yaml: line 3: mapping values are not allowed in this context
+`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeLint(&buf, tc.format, "rules", lintFixture()); err != nil {
+				t.Fatalf("writeLint error: %v", err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("unexpected %s output:\n%s\nwant:\n%s", tc.format, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWriteLintSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeLint(&buf, lintFormatSARIF, "rules", lintFixture()); err != nil {
+		t.Fatalf("writeLint error: %v", err)
+	}
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Results []struct {
+				RuleID  string `json:"ruleId"`
+				Level   string `json:"level"`
+				Message struct {
+					Text string `json:"text"`
+				} `json:"message"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region *struct {
+							StartLine   int `json:"startLine"`
+							StartColumn int `json:"startColumn"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid SARIF JSON: %v\n%s", err, buf.String())
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("expected one SARIF 2.1.0 run, got version %q with %d run(s)", log.Version, len(log.Runs))
+	}
+
+	cases := []struct {
+		level     string
+		text      string
+		uri       string
+		startLine int
+	}{
+		{level: "error", text: `low_checking: condition references unknown account "Chekcing"`, uri: "rules/alerts.yaml", startLine: 6},
+		{level: "warning", text: "low_checking: schedule present; day/week gates will be ignored", uri: "rules/alerts.yaml", startLine: 4},
+		{level: "error", text: "load: yaml: line 3: mapping values are not allowed in this context"},
+	}
+	results := log.Runs[0].Results
+	if len(results) != len(cases) {
+		t.Fatalf("expected %d results, got %d", len(cases), len(results))
+	}
+	for i, tc := range cases {
+		got := results[i]
+		if got.RuleID != sarifRuleID || got.Level != tc.level || got.Message.Text != tc.text {
+			t.Fatalf("result %d: got ruleId %q level %q message %q", i, got.RuleID, got.Level, got.Message.Text)
+		}
+		if tc.uri == "" {
+			if len(got.Locations) != 0 {
+				t.Fatalf("result %d: expected no location, got %+v", i, got.Locations)
+			}
+			continue
+		}
+		if len(got.Locations) != 1 {
+			t.Fatalf("result %d: expected one location, got %+v", i, got.Locations)
+		}
+		loc := got.Locations[0].PhysicalLocation
+		if loc.ArtifactLocation.URI != tc.uri || loc.Region == nil || loc.Region.StartLine != tc.startLine {
+			t.Fatalf("result %d: expected %s line %d, got %+v", i, tc.uri, tc.startLine, loc)
+		}
+	}
+}
+
+func TestWriteLintGitHubEscapes(t *testing.T) {
+	results := []rules.LintResult{{
+		Name:        "a,b:c",
+		Diagnostics: []rules.Diagnostic{{Level: rules.LintWarning, Message: "50% off\nnext line", Pos: rules.Position{File: "x.yaml", Line: 1, Column: 1}}},
+	}}
+	var buf bytes.Buffer
+	if err := writeLint(&buf, lintFormatGitHub, "rules", results); err != nil {
+		t.Fatalf("writeLint error: %v", err)
+	}
+	want := "::warning file=rules/x.yaml,line=1,col=1,title=a%2Cb%3Ac::50%25 off%0Anext line\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestWriteLintUnknownFormat(t *testing.T) {
+	err := writeLint(&bytes.Buffer{}, "xml", "rules", lintFixture())
+	if err == nil || !strings.Contains(err.Error(), `unknown lint format "xml"`) {
+		t.Fatalf("expected unknown format error, got %v", err)
+	}
+}
@@ -4,15 +4,21 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	_ "time/tzdata" // timezone names resolve in minimal containers
+
 	"github.com/spf13/cobra"
 
 	"ynab-alerts/internal/config"
+	"ynab-alerts/internal/daytime"
 	"ynab-alerts/internal/heartbeat"
 	"ynab-alerts/internal/notifier"
 	"ynab-alerts/internal/rules"
@@ -40,6 +46,14 @@ var (
 	flagHBInterval   string
 	flagHBGrace      string
 	flagHBDesc       string
+	flagClearDead    bool
+	flagHTTPAddr     string
+	flagAckBy        string
+	flagSnoozeFor    string
+	flagSnoozeUntil  string
+	flagReason       string
+	flagLintOnline   bool
+	flagLintFormat   string
 )
 
 func main() {
@@ -58,13 +72,14 @@ func main() {
 	rootCmd.PersistentFlags().StringVar(&flagBudget, "budget", "", "YNAB budget ID (overrides YNAB_BUDGET_ID)")
 	rootCmd.PersistentFlags().StringVar(&flagBaseURL, "base-url", "", "YNAB API base URL")
 	rootCmd.PersistentFlags().StringVar(&flagRulesDir, "rules", "", "Directory of YAML rule files")
-	rootCmd.PersistentFlags().StringVar(&flagNotifier, "notifier", "", "Notifier kind (pushover|log)")
+	rootCmd.PersistentFlags().StringVar(&flagNotifier, "notifier", "", "Notifier kind(s), comma-separated (pushover|log|exec|nats|mqtt)")
 	rootCmd.PersistentFlags().StringVar(&flagPollInterval, "poll", "", "Poll interval (e.g. 1m)")
 	rootCmd.PersistentFlags().StringVar(&flagObservePath, "observe-path", "", "Path to observation store (default XDG cache)")
 	rootCmd.PersistentFlags().BoolVar(&flagDebug, "debug", false, "Enable debug logging")
 	rootCmd.PersistentFlags().StringVar(&flagConfigPath, "config", "", "Path to config file (YAML/JSON)")
-	rootCmd.PersistentFlags().StringVar(&flagDayStart, "day-start", "", "Earliest time of day to evaluate (HH:MM, 24h)")
-	rootCmd.PersistentFlags().StringVar(&flagDayEnd, "day-end", "", "Latest time of day to evaluate (HH:MM, 24h)")
+	rootCmd.PersistentFlags().StringVar(&flagDayStart, "day-start", "", "Earliest time of day to deliver alerts (HH:MM, 24h)")
+	rootCmd.PersistentFlags().StringVar(&flagDayEnd, "day-end", "", "Latest time of day to deliver alerts (HH:MM, 24h)")
+	rootCmd.PersistentFlags().StringVar(&flagHTTPAddr, "http-addr", "", "Listen address for the status endpoint (e.g. :8080)")
 	rootCmd.PersistentFlags().BoolVar(&flagHBEnabled, "heartbeat", false, "Enable heartbeat publishing")
 	rootCmd.PersistentFlags().StringVar(&flagHBNATSURL, "heartbeat-nats-url", "", "NATS URL to publish heartbeats")
 	rootCmd.PersistentFlags().StringVar(&flagHBSubject, "heartbeat-subject", "", "Heartbeat subject (appended to prefix)")
@@ -120,33 +135,176 @@ func main() {
 		Use:   "lint",
 		Short: "Lint rule files for common issues",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadBaseConfig(cmd)
+			if err != nil {
+				return err
+			}
 			rulesDir := resolveRulesDirForLint(cmd)
-			pollInterval := resolvePollIntervalForLint(nil)
-			now := time.Now()
-			results, err := rules.LintWithPoll(rulesDir, now, pollInterval)
+			if cmd.Flags().Changed("notifier") {
+				cfg.Notifier, cfg.NotifierSet = strings.TrimSpace(flagNotifier), true
+			}
+			opts := rules.LintOptions{PollInterval: resolvePollIntervalForLint(&cfg)}
+			if cfg.NotifierSet {
+				// without a configured notifier there is nothing to check notify lists against
+				opts.Channels = cfg.Notifiers()
+			}
+			if len(cfg.Holidays) > 0 {
+				if opts.Calendar, err = rules.LoadCalendar(cfg.Holidays...); err != nil {
+					return err
+				}
+			}
+			if opts.Accounts, opts.CachedNames, err = lintAccountNames(cmd, cfg); err != nil {
+				return err
+			}
+			now := time.Now().In(cfg.Location())
+			results, err := rules.LintWithOptions(rulesDir, now, opts)
 			if err != nil {
+				results = []rules.LintResult{rules.LoadFailure(err)}
+			}
+			if err := writeLint(os.Stdout, strings.ToLower(strings.TrimSpace(flagLintFormat)), rulesDir, results); err != nil {
 				return err
 			}
+			errs := 0
 			for _, r := range results {
-				next := "unknown"
-				if r.HasNext {
-					next = r.NextEval.Format(time.RFC3339)
-				}
-				fmt.Printf("%s:\n  next: %s\n", r.Name, next)
-				if len(r.Issues) == 0 {
-					fmt.Println("  issues: none")
-				} else {
-					fmt.Println("  issues:")
-					for _, i := range r.Issues {
-						fmt.Printf("    - %s\n", i)
-					}
+				errs += r.Errors()
+			}
+			if errs > 0 {
+				cmd.SilenceUsage, cmd.SilenceErrors = true, true
+				return fmt.Errorf("lint found %d error(s)", errs)
+			}
+			return nil
+		},
+	}
+	lintCmd.Flags().StringVar(&flagLintFormat, "format", lintFormatText, "Output format (text|json|sarif|github)")
+	lintCmd.Flags().BoolVar(&flagLintOnline, "online", false, "Check account names against the live budget instead of the daemon's cache")
+
+	deadLettersCmd := &cobra.Command{
+		Use:   "dead-letters",
+		Short: "List alerts that could not be delivered after retrying",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadBaseConfig(cmd)
+			if err != nil {
+				return err
+			}
+			outbox, err := notifier.OpenOutbox(cfg.Queue.Path)
+			if err != nil {
+				return err
+			}
+			if flagClearDead {
+				n, err := outbox.ClearDeadLetters()
+				if err != nil {
+					return err
 				}
+				fmt.Printf("cleared %d dead letter(s)\n", n)
+				return nil
+			}
+			return listDeadLetters(outbox)
+		},
+	}
+	deadLettersCmd.Flags().BoolVar(&flagClearDead, "clear", false, "Remove all dead letters")
+
+	ackCmd := &cobra.Command{
+		Use:   "ack <rule>",
+		Short: "Acknowledge a rule's active alert and stop escalation",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, _, err := openStore(cmd)
+			if err != nil {
+				return err
+			}
+			ok, err := store.Acknowledge(args[0], flagAckBy, time.Now())
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("no active alert for %s", args[0])
+			}
+			fmt.Printf("acknowledged %s\n", args[0])
+			return nil
+		},
+	}
+	ackCmd.Flags().StringVar(&flagAckBy, "by", "cli", "Who acknowledged the alert")
+
+	snoozeCmd := &cobra.Command{
+		Use:   "snooze <rule>",
+		Short: "Silence a rule's alerts for a while (--for 3d or --until 2026-11-01)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, cfg, err := openStore(cmd)
+			if err != nil {
+				return err
+			}
+			now := time.Now().In(cfg.Location())
+			until, err := resolveSnoozeUntil(now)
+			if err != nil {
+				return err
 			}
+			sil := rules.Silence{Until: until, Reason: strings.TrimSpace(flagReason), CreatedAt: now}
+			if err := store.Silence(args[0], sil); err != nil {
+				return err
+			}
+			fmt.Printf("snoozed %s until %s\n", args[0], until.Format(time.RFC3339))
 			return nil
 		},
 	}
+	snoozeCmd.Flags().StringVar(&flagSnoozeFor, "for", "", "Snooze duration (e.g. 90m, 12h, 3d)")
+	snoozeCmd.Flags().StringVar(&flagSnoozeUntil, "until", "", "Snooze until a date (YYYY-MM-DD, local midnight) or RFC3339 time")
+	snoozeCmd.Flags().StringVar(&flagReason, "reason", "", "Why the rule is snoozed")
 
-	rootCmd.AddCommand(runCmd, listBudgetsCmd, listAccountsCmd, lintCmd)
+	muteCmd := &cobra.Command{
+		Use:   "mute <rule>",
+		Short: "Silence a rule's alerts until unmuted",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, _, err := openStore(cmd)
+			if err != nil {
+				return err
+			}
+			sil := rules.Silence{Reason: strings.TrimSpace(flagReason), CreatedAt: time.Now()}
+			if err := store.Silence(args[0], sil); err != nil {
+				return err
+			}
+			fmt.Printf("muted %s\n", args[0])
+			return nil
+		},
+	}
+	muteCmd.Flags().StringVar(&flagReason, "reason", "", "Why the rule is muted")
+
+	unmuteCmd := &cobra.Command{
+		Use:   "unmute <rule>",
+		Short: "Remove a rule's snooze or mute",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, _, err := openStore(cmd)
+			if err != nil {
+				return err
+			}
+			ok, err := store.Unsilence(args[0])
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("%s is not snoozed or muted", args[0])
+			}
+			fmt.Printf("unmuted %s\n", args[0])
+			return nil
+		},
+	}
+
+	silencesCmd := &cobra.Command{
+		Use:   "silences",
+		Short: "List snoozed and muted rules",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, _, err := openStore(cmd)
+			if err != nil {
+				return err
+			}
+			return listSilences(store, time.Now())
+		},
+	}
+
+	rootCmd.AddCommand(runCmd, listBudgetsCmd, listAccountsCmd, lintCmd, deadLettersCmd, ackCmd,
+		snoozeCmd, muteCmd, unmuteCmd, silencesCmd)
 
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		log.Fatalf("error: %v", err)
@@ -188,19 +346,22 @@ func runDaemon(ctx context.Context, cmd *cobra.Command) error {
 		cfg.Debug = flagDebug
 	}
 	if cmd.Flags().Changed("day-start") {
-		dur, err := config.ParseTimeOfDay(flagDayStart)
+		dur, err := daytime.Parse(flagDayStart)
 		if err != nil {
 			return fmt.Errorf("invalid day-start: %w", err)
 		}
 		cfg.DayStart = dur
 	}
 	if cmd.Flags().Changed("day-end") {
-		dur, err := config.ParseTimeOfDay(flagDayEnd)
+		dur, err := daytime.Parse(flagDayEnd)
 		if err != nil {
 			return fmt.Errorf("invalid day-end: %w", err)
 		}
 		cfg.DayEnd = dur
 	}
+	if cmd.Flags().Changed("http-addr") {
+		cfg.HTTPAddr = strings.TrimSpace(flagHTTPAddr)
+	}
 	if cmd.Flags().Changed("heartbeat") {
 		cfg.Heartbeat.Enabled = flagHBEnabled
 	}
@@ -243,17 +404,69 @@ func runDaemon(ctx context.Context, cmd *cobra.Command) error {
 		return fmt.Errorf("observation store error: %w", err)
 	}
 
-	notif, err := notifier.Build(notifier.Options{
-		Kind: cfg.Notifier,
+	opts := notifier.Options{
 		Pushover: notifier.PushoverConfig{
 			AppToken: cfg.Pushover.AppToken,
 			UserKey:  cfg.Pushover.UserKey,
 			Device:   cfg.Pushover.Device,
+			OnAcknowledge: func(rule, by string, at time.Time) {
+				if _, err := store.Acknowledge(rule, by, at); err != nil {
+					log.Printf("acknowledge %s failed: %v", rule, err)
+					return
+				}
+				log.Printf("alert for %s acknowledged by %q", rule, by)
+			},
 		},
-	})
+		Exec: notifier.ExecConfig{
+			Command: cfg.Exec.Command,
+			Args:    cfg.Exec.Args,
+			Timeout: cfg.Exec.Timeout,
+		},
+		NATS: notifier.NATSConfig{
+			URL:       cfg.NATSURL(),
+			Subject:   cfg.NATS.Subject,
+			JetStream: cfg.NATS.JetStream,
+		},
+		MQTT: notifier.MQTTConfig{
+			Broker:      cfg.MQTT.Broker,
+			ClientID:    cfg.MQTT.ClientID,
+			Username:    cfg.MQTT.Username,
+			Password:    cfg.MQTT.Password,
+			Topic:       cfg.MQTT.Topic,
+			QoS:         byte(cfg.MQTT.QoS),
+			Retain:      cfg.MQTT.Retain,
+			StateTopics: cfg.MQTT.StateTopics,
+			StatePrefix: cfg.MQTT.StatePrefix,
+			TLS:         cfg.MQTT.TLS,
+			CAFile:      cfg.MQTT.CAFile,
+			Insecure:    cfg.MQTT.Insecure,
+		},
+	}
+	var channels []notifier.Channel
+	for _, kind := range cfg.Notifiers() {
+		opts.Kind = kind
+		n, err := notifier.Build(opts)
+		if err != nil {
+			notifier.NewFanout(channels, nil, notifier.RetryPolicy{}).Close()
+			return fmt.Errorf("notifier error: %w", err)
+		}
+		ch := notifier.Channel{Name: kind, Notifier: n}
+		if q, ok := cfg.QuietHours[kind]; ok {
+			loc := cfg.Location()
+			ch.Quiet = func(t time.Time) bool { return q.Contains(t.In(loc)) }
+		}
+		channels = append(channels, ch)
+	}
+	outbox, err := notifier.OpenOutbox(cfg.Queue.Path)
 	if err != nil {
-		return fmt.Errorf("notifier error: %w", err)
+		return fmt.Errorf("queue error: %w", err)
 	}
+	notif := notifier.NewFanout(channels, outbox, notifier.RetryPolicy{
+		Backoff:    cfg.Queue.Backoff,
+		MaxBackoff: cfg.Queue.MaxBackoff,
+		MaxAge:     cfg.Queue.MaxAge,
+	})
+	defer notif.Close()
 
 	ynabClient := ynab.NewClient(cfg.APIToken, cfg.BaseURL)
 	if cfg.Debug {
@@ -275,6 +488,17 @@ func runDaemon(ctx context.Context, cmd *cobra.Command) error {
 		defer stopHeartbeat()
 	}
 
+	if cfg.HTTPAddr != "" {
+		srv := &http.Server{Addr: cfg.HTTPAddr, Handler: svc.Handler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("status server error: %v", err)
+			}
+		}()
+		defer srv.Close()
+		log.Printf("status endpoint listening on %s", cfg.HTTPAddr)
+	}
+
 	log.Println("ynab-alerts daemon starting")
 	if err := svc.Run(daemonCtx); err != nil && daemonCtx.Err() == nil {
 		return err
@@ -321,6 +545,41 @@ func resolveRulesDirForLint(cmd *cobra.Command) string {
 	return "rules"
 }
 
+// lintAccountNames returns the account names lint checks references against:
+// the live budget with --online, otherwise the names cached by the daemon's
+// last poll, reported as cached so misses only warn. It returns nil (no
+// check) when there is no cache yet.
+func lintAccountNames(cmd *cobra.Command, cfg config.Config) ([]string, bool, error) {
+	if flagLintOnline {
+		budget := resolveBudget(cfg, "")
+		if budget == "" {
+			return nil, false, fmt.Errorf("budget ID required via --budget or YNAB_BUDGET_ID for --online")
+		}
+		client := ynab.NewClient(resolveToken(cfg), resolveBaseURL(cfg))
+		accounts, err := client.GetAccounts(cmd.Context(), budget)
+		if err != nil {
+			return nil, false, fmt.Errorf("fetching accounts: %w", err)
+		}
+		names := make([]string, 0, len(accounts))
+		for _, a := range accounts {
+			names = append(names, a.Name)
+		}
+		return names, false, nil
+	}
+	path := cfg.ObservePath
+	if cmd.Flags().Changed("observe-path") {
+		path = strings.TrimSpace(flagObservePath)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, false, nil
+	}
+	store, err := rules.NewStore(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("observation store error: %w", err)
+	}
+	return store.Accounts(), true, nil
+}
+
 func resolvePollIntervalForLint(cfg *config.Config) time.Duration {
 	if strings.TrimSpace(flagPollInterval) != "" {
 		if dur, err := time.ParseDuration(flagPollInterval); err == nil {
@@ -359,6 +618,92 @@ func loadBaseConfig(cmd *cobra.Command) (config.Config, error) {
 	return cfg, nil
 }
 
+// openStore opens the observation store the daemon uses, honoring
+// --observe-path, and returns the config it was found from.
+func openStore(cmd *cobra.Command) (*rules.Store, config.Config, error) {
+	cfg, err := loadBaseConfig(cmd)
+	if err != nil {
+		return nil, cfg, err
+	}
+	if cmd.Flags().Changed("observe-path") {
+		cfg.ObservePath = strings.TrimSpace(flagObservePath)
+	}
+	store, err := rules.NewStore(cfg.ObservePath)
+	if err != nil {
+		return nil, cfg, fmt.Errorf("observation store error: %w", err)
+	}
+	return store, cfg, nil
+}
+
+// resolveSnoozeUntil turns --for or --until into an absolute end time.
+func resolveSnoozeUntil(now time.Time) (time.Time, error) {
+	forVal, untilVal := strings.TrimSpace(flagSnoozeFor), strings.TrimSpace(flagSnoozeUntil)
+	switch {
+	case forVal != "" && untilVal != "":
+		return time.Time{}, fmt.Errorf("use either --for or --until, not both")
+	case forVal != "":
+		dur, err := parseSnoozeDuration(forVal)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(dur), nil
+	case untilVal != "":
+		if t, err := time.ParseInLocation("2006-01-02", untilVal, now.Location()); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse(time.RFC3339, untilVal)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid --until %q, expected YYYY-MM-DD or RFC3339", untilVal)
+		}
+		return t, nil
+	default:
+		return time.Time{}, fmt.Errorf("--for or --until is required")
+	}
+}
+
+// parseSnoozeDuration accepts Go durations plus a whole-day "d" suffix.
+func parseSnoozeDuration(val string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(val, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid --for %q", val)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	dur, err := time.ParseDuration(val)
+	if err != nil || dur <= 0 {
+		return 0, fmt.Errorf("invalid --for %q", val)
+	}
+	return dur, nil
+}
+
+func listSilences(store *rules.Store, now time.Time) error {
+	silences := store.Silences()
+	names := make([]string, 0, len(silences))
+	for name, sil := range silences {
+		if sil.Active(now) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Println("no snoozed or muted rules")
+		return nil
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sil := silences[name]
+		state := "muted"
+		if !sil.Muted() {
+			state = "snoozed until " + sil.Until.Format(time.RFC3339)
+		}
+		if sil.Reason != "" {
+			state += " (" + sil.Reason + ")"
+		}
+		fmt.Printf("%s: %s\n", name, state)
+	}
+	return nil
+}
+
 func resolveToken(cfg config.Config) string {
 	if strings.TrimSpace(flagToken) != "" {
 		return strings.TrimSpace(flagToken)
@@ -415,6 +760,35 @@ func listAccounts(ctx context.Context, client *ynab.Client, budgetID string) err
 	return nil
 }
 
+func listDeadLetters(outbox *notifier.Outbox) error {
+	pending, err := outbox.Pending()
+	if err != nil {
+		return err
+	}
+	dead, err := outbox.DeadLetters()
+	if err != nil {
+		return err
+	}
+	deferred, err := outbox.Deferred()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		fmt.Printf("%d delivery(ies) pending retry\n", len(pending))
+	}
+	if len(deferred) > 0 {
+		fmt.Printf("%d delivery(ies) deferred by quiet hours\n", len(deferred))
+	}
+	if len(dead) == 0 {
+		fmt.Println("no dead letters")
+		return nil
+	}
+	for _, q := range dead {
+		fmt.Printf("%s\t%s\t%s\tattempts=%d\t%s\n", q.Alert.Time.Format(time.RFC3339), q.Channel, q.Alert.Rule, q.Attempts, q.LastError)
+	}
+	return nil
+}
+
 func formatMoney(milli int64, cf *ynab.CurrencyFormat) string {
 	sign := ""
 	if milli < 0 {
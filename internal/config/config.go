@@ -9,7 +9,11 @@ import (
 	"time"
 
 	"fmt"
+
+	"github.com/robfig/cron/v3"
 	"gopkg.in/yaml.v3"
+
+	"ynab-alerts/internal/daytime"
 )
 
 // Config holds runtime settings for the daemon.
@@ -20,11 +24,23 @@ type Config struct {
 	RulesDir     string
 	PollInterval time.Duration
 	Notifier     string
+	NotifierSet  bool // Notifier was set by a file, env var or flag rather than defaulted
 	Pushover     PushoverConfig
+	Exec         ExecConfig
+	NATS         NATSConfig
+	MQTT         MQTTConfig
+	Queue        QueueConfig
+	Digest       DigestConfig
 	ObservePath  string
 	Debug        bool
-	DayStart     time.Duration // offset from midnight (optional)
-	DayEnd       time.Duration // offset from midnight (optional)
+	DayStart     time.Duration            // offset from midnight in Timezone (optional); deliveries outside are deferred
+	DayEnd       time.Duration            // offset from midnight (optional)
+	QuietHours   map[string]daytime.Range // per notifier channel; deliveries inside are deferred
+	Routing      map[string][]string      // severity -> notifier channels ("digest" batches)
+	HTTPAddr     string                   // listen address for the status endpoint (optional)
+	Escalation   map[string][]EscalationStep
+	Holidays     []string // holiday calendar files (.ics or YAML) for business-day gates
+	Timezone     string   // IANA zone for gates, schedules and windows; empty = host local
 	Heartbeat    HeartbeatConfig
 }
 
@@ -35,6 +51,58 @@ type PushoverConfig struct {
 	Device   string
 }
 
+// ExecConfig describes a local command run for each alert by the exec notifier.
+type ExecConfig struct {
+	Command string
+	Args    []string
+	Timeout time.Duration
+}
+
+// NATSConfig controls publishing alerts to NATS. URL falls back to the
+// heartbeat NATS URL when empty.
+type NATSConfig struct {
+	URL       string
+	Subject   string
+	JetStream bool
+}
+
+// MQTTConfig controls publishing alerts and per-rule state to an MQTT broker.
+type MQTTConfig struct {
+	Broker      string
+	ClientID    string
+	Username    string
+	Password    string
+	Topic       string
+	QoS         int
+	Retain      bool
+	StateTopics bool
+	StatePrefix string
+	TLS         bool
+	CAFile      string
+	Insecure    bool
+}
+
+// QueueConfig controls the outbound retry queue for failed deliveries.
+type QueueConfig struct {
+	Path       string
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	MaxAge     time.Duration // dead-letter deliveries failing for longer than this
+}
+
+// EscalationStep notifies more channels when an alert is still
+// unacknowledged After it first fired.
+type EscalationStep struct {
+	After  time.Duration
+	Notify []string
+}
+
+// DigestConfig controls the summary sent for rules with delivery: digest.
+type DigestConfig struct {
+	Schedule string // cron "min hour dom mon dow"
+	AllClear bool   // send an "all clear" digest when nothing fired
+}
+
 // HeartbeatConfig controls NATS heartbeat publishing for liveness monitoring.
 type HeartbeatConfig struct {
 	Enabled     bool
@@ -51,6 +119,10 @@ const (
 	defaultRulesDir     = "rules"
 	defaultPollInterval = time.Hour
 	defaultNotifier     = "pushover"
+	defaultQueueBackoff = time.Minute
+	defaultQueueMaxWait = time.Hour
+	defaultQueueMaxAge  = 24 * time.Hour
+	defaultDigestSched  = "0 8 * * *"
 	defaultHBPfx        = "heartbeat"
 	defaultHBNATSURL    = "nats://localhost:4222"
 	defaultHBInterval   = time.Minute
@@ -67,6 +139,11 @@ func DefaultHeartbeatInterval() time.Duration {
 	return defaultHBInterval
 }
 
+// DefaultDigestSchedule returns the cron schedule used for digests when none is configured.
+func DefaultDigestSchedule() string {
+	return defaultDigestSched
+}
+
 // FromEnv builds a Config from environment variables.
 // Deprecated: prefer Load with a config file path if available.
 func FromEnv() (Config, error) {
@@ -99,11 +176,69 @@ func (c Config) Validate() error {
 	if c.BudgetID == "" {
 		return errors.New("YNAB_BUDGET_ID is required")
 	}
-	if c.Notifier == "pushover" {
+	if len(c.Notifiers()) == 0 {
+		return errors.New("at least one notifier is required")
+	}
+	if c.UsesNotifier("pushover") {
 		if c.Pushover.AppToken == "" || c.Pushover.UserKey == "" {
 			return errors.New("PUSHOVER_APP_TOKEN and PUSHOVER_USER_KEY are required for Pushover")
 		}
 	}
+	if c.UsesNotifier("exec") && strings.TrimSpace(c.Exec.Command) == "" {
+		return errors.New("exec command is required for the exec notifier")
+	}
+	if c.UsesNotifier("nats") && c.NATSURL() == "" {
+		return errors.New("nats url is required for the nats notifier")
+	}
+	if c.UsesNotifier("mqtt") {
+		if strings.TrimSpace(c.MQTT.Broker) == "" {
+			return errors.New("mqtt broker is required for the mqtt notifier")
+		}
+		if c.MQTT.QoS < 0 || c.MQTT.QoS > 2 {
+			return errors.New("mqtt qos must be 0, 1 or 2")
+		}
+	}
+	if c.Queue.Backoff < 0 || c.Queue.MaxBackoff < 0 || c.Queue.MaxAge < 0 {
+		return errors.New("queue durations cannot be negative")
+	}
+	if c.Digest.Schedule != "" {
+		if _, err := cron.ParseStandard(c.Digest.Schedule); err != nil {
+			return fmt.Errorf("digest schedule invalid: %w", err)
+		}
+	}
+	for sev := range c.Routing {
+		switch sev {
+		case "info", "warning", "critical":
+		default:
+			return fmt.Errorf("routing severity %q is invalid (info|warning|critical)", sev)
+		}
+	}
+	for name, steps := range c.Escalation {
+		if len(steps) == 0 {
+			return fmt.Errorf("escalation policy %q has no steps", name)
+		}
+		for i, step := range steps {
+			if step.After <= 0 {
+				return fmt.Errorf("escalation policy %q step %d: after must be > 0", name, i+1)
+			}
+			if i > 0 && step.After <= steps[i-1].After {
+				return fmt.Errorf("escalation policy %q step %d: after must increase", name, i+1)
+			}
+			if len(step.Notify) == 0 {
+				return fmt.Errorf("escalation policy %q step %d: notify is required", name, i+1)
+			}
+			for _, ch := range step.Notify {
+				if !c.UsesNotifier(strings.TrimSpace(ch)) {
+					return fmt.Errorf("escalation policy %q step %d: notify channel %q is not a configured notifier (%s)", name, i+1, ch, strings.Join(c.Notifiers(), "|"))
+				}
+			}
+		}
+	}
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("timezone invalid: %w", err)
+		}
+	}
 	if c.PollInterval <= 0 {
 		return errors.New("poll interval must be > 0")
 	}
@@ -133,6 +268,19 @@ func (c Config) Validate() error {
 	return nil
 }
 
+// Location returns the configured time zone, or the host's local zone when
+// unset or invalid (Validate reports invalid zones).
+func (c Config) Location() *time.Location {
+	if c.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
 // HeartbeatEnabled reports whether heartbeat publishing should run.
 func (c Config) HeartbeatEnabled() bool {
 	hb := c.Heartbeat
@@ -142,6 +290,52 @@ func (c Config) HeartbeatEnabled() bool {
 	return strings.TrimSpace(hb.NATSURL) != "" && strings.TrimSpace(hb.Subject) != ""
 }
 
+// Notifiers returns the configured notifier channels. Notifier accepts a
+// comma-separated list (e.g. "pushover,nats") to fan alerts out.
+func (c Config) Notifiers() []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, part := range strings.Split(c.Notifier, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" || seen[part] {
+			continue
+		}
+		seen[part] = true
+		out = append(out, part)
+	}
+	return out
+}
+
+// UsesNotifier reports whether kind is one of the configured notifiers.
+func (c Config) UsesNotifier(kind string) bool {
+	for _, n := range c.Notifiers() {
+		if n == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// NATSURL returns the server URL used for alert publishing, reusing the
+// heartbeat connection settings when no dedicated URL is configured.
+func (c Config) NATSURL() string {
+	if v := strings.TrimSpace(c.NATS.URL); v != "" {
+		return v
+	}
+	return strings.TrimSpace(c.Heartbeat.NATSURL)
+}
+
+// splitList splits a comma-separated value, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
 func valueOrDefault(val, def string) string {
 	if val == "" {
 		return def
@@ -182,28 +376,30 @@ func ParseMilliunits(v string) (int64, error) {
 	return int64(f * 1000), nil
 }
 
-// ParseTimeOfDay converts HH:MM (24h) to a duration offset from midnight.
-func ParseTimeOfDay(val string) (time.Duration, error) {
-	t, err := time.Parse("15:04", val)
-	if err != nil {
-		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", val)
-	}
-	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
-}
-
 type fileConfig struct {
-	Token        string         `yaml:"token"`
-	BudgetID     string         `yaml:"budget_id"`
-	BaseURL      string         `yaml:"base_url"`
-	RulesDir     string         `yaml:"rules_dir"`
-	PollInterval string         `yaml:"poll_interval"`
-	Notifier     string         `yaml:"notifier"`
-	ObservePath  string         `yaml:"observe_path"`
-	Debug        *bool          `yaml:"debug"`
-	DayStart     string         `yaml:"day_start"`
-	DayEnd       string         `yaml:"day_end"`
-	Pushover     pushoverBlock  `yaml:"pushover"`
-	Heartbeat    heartbeatBlock `yaml:"heartbeat"`
+	Token        string                           `yaml:"token"`
+	BudgetID     string                           `yaml:"budget_id"`
+	BaseURL      string                           `yaml:"base_url"`
+	RulesDir     string                           `yaml:"rules_dir"`
+	PollInterval string                           `yaml:"poll_interval"`
+	Notifier     string                           `yaml:"notifier"`
+	ObservePath  string                           `yaml:"observe_path"`
+	Debug        *bool                            `yaml:"debug"`
+	DayStart     string                           `yaml:"day_start"`
+	DayEnd       string                           `yaml:"day_end"`
+	QuietHours   map[string]string                `yaml:"quiet_hours"`
+	Routing      map[string][]string              `yaml:"routing"`
+	HTTPAddr     string                           `yaml:"http_addr"`
+	Escalation   map[string][]escalationStepBlock `yaml:"escalation"`
+	Holidays     []string                         `yaml:"holidays"`
+	Timezone     string                           `yaml:"timezone"`
+	Pushover     pushoverBlock                    `yaml:"pushover"`
+	Exec         execBlock                        `yaml:"exec"`
+	NATS         natsBlock                        `yaml:"nats"`
+	MQTT         mqttBlock                        `yaml:"mqtt"`
+	Queue        queueBlock                       `yaml:"queue"`
+	Digest       digestBlock                      `yaml:"digest"`
+	Heartbeat    heartbeatBlock                   `yaml:"heartbeat"`
 }
 
 type pushoverBlock struct {
@@ -212,6 +408,50 @@ type pushoverBlock struct {
 	Device   string `yaml:"device"`
 }
 
+type execBlock struct {
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+	Timeout string   `yaml:"timeout"`
+}
+
+type natsBlock struct {
+	URL       string `yaml:"url"`
+	Subject   string `yaml:"subject"`
+	JetStream *bool  `yaml:"jetstream"`
+}
+
+type mqttBlock struct {
+	Broker      string `yaml:"broker"`
+	ClientID    string `yaml:"client_id"`
+	Username    string `yaml:"username"`
+	Password    string `yaml:"password"`
+	Topic       string `yaml:"topic"`
+	QoS         *int   `yaml:"qos"`
+	Retain      *bool  `yaml:"retain"`
+	StateTopics *bool  `yaml:"state_topics"`
+	StatePrefix string `yaml:"state_prefix"`
+	TLS         *bool  `yaml:"tls"`
+	CAFile      string `yaml:"ca_file"`
+	Insecure    *bool  `yaml:"insecure_skip_verify"`
+}
+
+type queueBlock struct {
+	Path       string `yaml:"path"`
+	Backoff    string `yaml:"backoff"`
+	MaxBackoff string `yaml:"max_backoff"`
+	MaxAge     string `yaml:"max_age"`
+}
+
+type escalationStepBlock struct {
+	After  string   `yaml:"after"`
+	Notify []string `yaml:"notify"`
+}
+
+type digestBlock struct {
+	Schedule string `yaml:"schedule"`
+	AllClear *bool  `yaml:"all_clear"`
+}
+
 type heartbeatBlock struct {
 	Enabled     *bool  `yaml:"enabled"`
 	NATSURL     string `yaml:"nats_url"`
@@ -230,6 +470,7 @@ func defaultConfig() Config {
 		}
 	}
 	defaultObserve := filepath.Join(cacheDir, "ynab-alerts", "observations.json")
+	defaultQueue := filepath.Join(cacheDir, "ynab-alerts", "outbox.json")
 
 	return Config{
 		APIToken:     "",
@@ -243,6 +484,15 @@ func defaultConfig() Config {
 		Debug:        false,
 		DayStart:     0,
 		DayEnd:       0,
+		Queue: QueueConfig{
+			Path:       defaultQueue,
+			Backoff:    defaultQueueBackoff,
+			MaxBackoff: defaultQueueMaxWait,
+			MaxAge:     defaultQueueMaxAge,
+		},
+		Digest: DigestConfig{
+			Schedule: defaultDigestSched,
+		},
 		Heartbeat: HeartbeatConfig{
 			Enabled:     false,
 			NATSURL:     defaultHBNATSURL,
@@ -260,22 +510,56 @@ func applyEnv(cfg *Config) error {
 	cfg.BudgetID = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_BUDGET_ID")), cfg.BudgetID)
 	cfg.BaseURL = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_BASE_URL")), cfg.BaseURL)
 	cfg.RulesDir = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_RULES_DIR")), cfg.RulesDir)
-	cfg.Notifier = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_NOTIFIER")), cfg.Notifier)
+	if v := strings.TrimSpace(os.Getenv("YNAB_NOTIFIER")); v != "" {
+		cfg.Notifier, cfg.NotifierSet = v, true
+	}
 	cfg.ObservePath = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_OBSERVATIONS_PATH")), cfg.ObservePath)
 	cfg.Pushover.AppToken = valueOrDefault(strings.TrimSpace(os.Getenv("PUSHOVER_APP_TOKEN")), cfg.Pushover.AppToken)
 	cfg.Pushover.UserKey = valueOrDefault(strings.TrimSpace(os.Getenv("PUSHOVER_USER_KEY")), cfg.Pushover.UserKey)
 	cfg.Pushover.Device = valueOrDefault(strings.TrimSpace(os.Getenv("PUSHOVER_DEVICE")), cfg.Pushover.Device)
 
+	cfg.Exec.Command = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_EXEC_COMMAND")), cfg.Exec.Command)
+	if v := strings.TrimSpace(os.Getenv("YNAB_EXEC_TIMEOUT")); v != "" {
+		dur, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		cfg.Exec.Timeout = dur
+	}
+	cfg.NATS.URL = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_NATS_URL")), cfg.NATS.URL)
+	cfg.NATS.Subject = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_NATS_SUBJECT")), cfg.NATS.Subject)
+	cfg.NATS.JetStream = parseBoolEnv(os.Getenv("YNAB_NATS_JETSTREAM"), cfg.NATS.JetStream)
+	cfg.MQTT.Broker = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_MQTT_BROKER")), cfg.MQTT.Broker)
+	cfg.MQTT.Username = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_MQTT_USERNAME")), cfg.MQTT.Username)
+	cfg.MQTT.Password = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_MQTT_PASSWORD")), cfg.MQTT.Password)
+	cfg.MQTT.Topic = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_MQTT_TOPIC")), cfg.MQTT.Topic)
+	cfg.Queue.Path = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_QUEUE_PATH")), cfg.Queue.Path)
+	if v := strings.TrimSpace(os.Getenv("YNAB_QUEUE_MAX_AGE")); v != "" {
+		dur, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		cfg.Queue.MaxAge = dur
+	}
+	cfg.Digest.Schedule = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_DIGEST_SCHEDULE")), cfg.Digest.Schedule)
+	cfg.Digest.AllClear = parseBoolEnv(os.Getenv("YNAB_DIGEST_ALL_CLEAR"), cfg.Digest.AllClear)
+
+	cfg.HTTPAddr = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_HTTP_ADDR")), cfg.HTTPAddr)
+	cfg.Timezone = valueOrDefault(strings.TrimSpace(os.Getenv("YNAB_TIMEZONE")), cfg.Timezone)
+	if v := strings.TrimSpace(os.Getenv("YNAB_HOLIDAYS")); v != "" {
+		cfg.Holidays = splitList(v)
+	}
+
 	cfg.Debug = parseBoolEnv(os.Getenv("YNAB_DEBUG"), cfg.Debug)
 	if v := strings.TrimSpace(os.Getenv("YNAB_DAY_START")); v != "" {
-		if dur, err := ParseTimeOfDay(v); err == nil {
+		if dur, err := daytime.Parse(v); err == nil {
 			cfg.DayStart = dur
 		} else {
 			return err
 		}
 	}
 	if v := strings.TrimSpace(os.Getenv("YNAB_DAY_END")); v != "" {
-		if dur, err := ParseTimeOfDay(v); err == nil {
+		if dur, err := daytime.Parse(v); err == nil {
 			cfg.DayEnd = dur
 		} else {
 			return err
@@ -343,6 +627,7 @@ func applyFile(cfg *Config, path string) error {
 	}
 	if fc.Notifier != "" {
 		cfg.Notifier = strings.TrimSpace(fc.Notifier)
+		cfg.NotifierSet = true
 	}
 	if fc.ObservePath != "" {
 		cfg.ObservePath = strings.TrimSpace(fc.ObservePath)
@@ -358,19 +643,47 @@ func applyFile(cfg *Config, path string) error {
 		cfg.Debug = *fc.Debug
 	}
 	if fc.DayStart != "" {
-		dur, err := ParseTimeOfDay(strings.TrimSpace(fc.DayStart))
+		dur, err := daytime.Parse(strings.TrimSpace(fc.DayStart))
 		if err != nil {
 			return err
 		}
 		cfg.DayStart = dur
 	}
 	if fc.DayEnd != "" {
-		dur, err := ParseTimeOfDay(strings.TrimSpace(fc.DayEnd))
+		dur, err := daytime.Parse(strings.TrimSpace(fc.DayEnd))
 		if err != nil {
 			return err
 		}
 		cfg.DayEnd = dur
 	}
+	for channel, raw := range fc.QuietHours {
+		q, err := daytime.ParseRange(raw)
+		if err != nil {
+			return fmt.Errorf("quiet_hours %s: %w", channel, err)
+		}
+		if cfg.QuietHours == nil {
+			cfg.QuietHours = map[string]daytime.Range{}
+		}
+		cfg.QuietHours[strings.TrimSpace(channel)] = q
+	}
+	for sev, channels := range fc.Routing {
+		if cfg.Routing == nil {
+			cfg.Routing = map[string][]string{}
+		}
+		cfg.Routing[strings.ToLower(strings.TrimSpace(sev))] = channels
+	}
+	if fc.HTTPAddr != "" {
+		cfg.HTTPAddr = strings.TrimSpace(fc.HTTPAddr)
+	}
+	if len(fc.Holidays) > 0 {
+		cfg.Holidays = fc.Holidays
+	}
+	if fc.Timezone != "" {
+		cfg.Timezone = strings.TrimSpace(fc.Timezone)
+	}
+	if err := applyEscalationBlock(cfg, fc.Escalation); err != nil {
+		return err
+	}
 	if fc.Pushover.AppToken != "" {
 		cfg.Pushover.AppToken = strings.TrimSpace(fc.Pushover.AppToken)
 	}
@@ -380,6 +693,38 @@ func applyFile(cfg *Config, path string) error {
 	if fc.Pushover.Device != "" {
 		cfg.Pushover.Device = strings.TrimSpace(fc.Pushover.Device)
 	}
+	if fc.Exec.Command != "" {
+		cfg.Exec.Command = strings.TrimSpace(fc.Exec.Command)
+	}
+	if len(fc.Exec.Args) > 0 {
+		cfg.Exec.Args = fc.Exec.Args
+	}
+	if fc.Exec.Timeout != "" {
+		dur, err := time.ParseDuration(strings.TrimSpace(fc.Exec.Timeout))
+		if err != nil {
+			return err
+		}
+		cfg.Exec.Timeout = dur
+	}
+	if fc.NATS.URL != "" {
+		cfg.NATS.URL = strings.TrimSpace(fc.NATS.URL)
+	}
+	if fc.NATS.Subject != "" {
+		cfg.NATS.Subject = strings.TrimSpace(fc.NATS.Subject)
+	}
+	if fc.NATS.JetStream != nil {
+		cfg.NATS.JetStream = *fc.NATS.JetStream
+	}
+	applyMQTTBlock(&cfg.MQTT, fc.MQTT)
+	if err := applyQueueBlock(&cfg.Queue, fc.Queue); err != nil {
+		return err
+	}
+	if fc.Digest.Schedule != "" {
+		cfg.Digest.Schedule = strings.TrimSpace(fc.Digest.Schedule)
+	}
+	if fc.Digest.AllClear != nil {
+		cfg.Digest.AllClear = *fc.Digest.AllClear
+	}
 	if fc.Heartbeat.Enabled != nil {
 		cfg.Heartbeat.Enabled = *fc.Heartbeat.Enabled
 	}
@@ -411,3 +756,84 @@ func applyFile(cfg *Config, path string) error {
 	}
 	return nil
 }
+
+func applyMQTTBlock(cfg *MQTTConfig, b mqttBlock) {
+	if b.Broker != "" {
+		cfg.Broker = strings.TrimSpace(b.Broker)
+	}
+	if b.ClientID != "" {
+		cfg.ClientID = strings.TrimSpace(b.ClientID)
+	}
+	if b.Username != "" {
+		cfg.Username = strings.TrimSpace(b.Username)
+	}
+	if b.Password != "" {
+		cfg.Password = b.Password
+	}
+	if b.Topic != "" {
+		cfg.Topic = strings.TrimSpace(b.Topic)
+	}
+	if b.QoS != nil {
+		cfg.QoS = *b.QoS
+	}
+	if b.Retain != nil {
+		cfg.Retain = *b.Retain
+	}
+	if b.StateTopics != nil {
+		cfg.StateTopics = *b.StateTopics
+	}
+	if b.StatePrefix != "" {
+		cfg.StatePrefix = strings.TrimSpace(b.StatePrefix)
+	}
+	if b.TLS != nil {
+		cfg.TLS = *b.TLS
+	}
+	if b.CAFile != "" {
+		cfg.CAFile = strings.TrimSpace(b.CAFile)
+	}
+	if b.Insecure != nil {
+		cfg.Insecure = *b.Insecure
+	}
+}
+
+func applyEscalationBlock(cfg *Config, policies map[string][]escalationStepBlock) error {
+	for name, blocks := range policies {
+		steps := make([]EscalationStep, 0, len(blocks))
+		for i, b := range blocks {
+			dur, err := time.ParseDuration(strings.TrimSpace(b.After))
+			if err != nil {
+				return fmt.Errorf("escalation %s step %d: %w", name, i+1, err)
+			}
+			steps = append(steps, EscalationStep{After: dur, Notify: b.Notify})
+		}
+		if cfg.Escalation == nil {
+			cfg.Escalation = map[string][]EscalationStep{}
+		}
+		cfg.Escalation[strings.TrimSpace(name)] = steps
+	}
+	return nil
+}
+
+func applyQueueBlock(cfg *QueueConfig, b queueBlock) error {
+	if b.Path != "" {
+		cfg.Path = strings.TrimSpace(b.Path)
+	}
+	for _, d := range []struct {
+		raw    string
+		target *time.Duration
+	}{
+		{b.Backoff, &cfg.Backoff},
+		{b.MaxBackoff, &cfg.MaxBackoff},
+		{b.MaxAge, &cfg.MaxAge},
+	} {
+		if d.raw == "" {
+			continue
+		}
+		dur, err := time.ParseDuration(strings.TrimSpace(d.raw))
+		if err != nil {
+			return err
+		}
+		*d.target = dur
+	}
+	return nil
+}
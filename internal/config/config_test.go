@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -40,6 +41,9 @@ func TestFromEnvDefaultsAndOverrides(t *testing.T) {
 	if cfg.DayStart != 6*time.Hour || cfg.DayEnd != 22*time.Hour {
 		t.Fatalf("expected day window 06:00-22:00, got %s-%s", cfg.DayStart, cfg.DayEnd)
 	}
+	if cfg.Notifier != "pushover" || cfg.NotifierSet {
+		t.Fatalf("expected the default notifier to be marked unset, got %q (%v)", cfg.Notifier, cfg.NotifierSet)
+	}
 }
 
 func TestPollIntervalOverride(t *testing.T) {
@@ -161,3 +165,246 @@ pushover:
 		t.Fatalf("pushover block not loaded: %+v", cfg.Pushover)
 	}
 }
+
+func TestExecNotifierConfig(t *testing.T) {
+	file := t.TempDir() + "/config.yaml"
+	content := `
+token: tok
+budget_id: bud
+notifier: exec
+exec:
+  command: notify-send
+  args: ["--urgency=critical"]
+  timeout: 5s
+`
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	t.Setenv("YNAB_EXEC_COMMAND", "")
+
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected validate error: %v", err)
+	}
+	if cfg.Exec.Command != "notify-send" || len(cfg.Exec.Args) != 1 || cfg.Exec.Timeout != 5*time.Second {
+		t.Fatalf("exec block not loaded: %+v", cfg.Exec)
+	}
+
+	cfg.Exec.Command = ""
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected error when exec command is missing")
+	}
+}
+
+func TestNATSURLFallsBackToHeartbeat(t *testing.T) {
+	cfg := Config{
+		APIToken:     "token",
+		BudgetID:     "budget",
+		Notifier:     "nats",
+		PollInterval: time.Hour,
+		Heartbeat:    HeartbeatConfig{NATSURL: "nats://hb:4222"},
+	}
+	if got := cfg.NATSURL(); got != "nats://hb:4222" {
+		t.Fatalf("expected heartbeat url fallback, got %q", got)
+	}
+	cfg.NATS.URL = "nats://alerts:4222"
+	if got := cfg.NATSURL(); got != "nats://alerts:4222" {
+		t.Fatalf("expected dedicated nats url, got %q", got)
+	}
+	cfg.NATS.URL = ""
+	cfg.Heartbeat.NATSURL = ""
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected error when no nats url is available")
+	}
+}
+
+func TestMQTTBlock(t *testing.T) {
+	file := t.TempDir() + "/config.yaml"
+	content := `
+token: tok
+budget_id: bud
+notifier: mqtt
+mqtt:
+  broker: ssl://broker:8883
+  username: ha
+  password: secret
+  qos: 1
+  state_topics: true
+  tls: true
+`
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	t.Setenv("YNAB_MQTT_BROKER", "")
+
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected validate error: %v", err)
+	}
+	m := cfg.MQTT
+	if m.Broker != "ssl://broker:8883" || m.Username != "ha" || m.Password != "secret" || m.QoS != 1 || !m.StateTopics || !m.TLS {
+		t.Fatalf("mqtt block not loaded: %+v", m)
+	}
+
+	cfg.MQTT.QoS = 3
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected error for invalid qos")
+	}
+}
+
+func TestNotifierListAndQueue(t *testing.T) {
+	file := t.TempDir() + "/config.yaml"
+	content := `
+token: tok
+budget_id: bud
+notifier: "log, exec, log"
+exec:
+  command: /usr/local/bin/led-sign
+queue:
+  path: /tmp/outbox.json
+  backoff: 30s
+  max_age: 6h
+`
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	t.Setenv("YNAB_QUEUE_PATH", "")
+	t.Setenv("YNAB_QUEUE_MAX_AGE", "")
+
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected validate error: %v", err)
+	}
+	if got := cfg.Notifiers(); len(got) != 2 || got[0] != "log" || got[1] != "exec" {
+		t.Fatalf("unexpected notifier list: %v", got)
+	}
+	if cfg.UsesNotifier("pushover") || !cfg.NotifierSet {
+		t.Fatalf("pushover should not be in use and the file's notifier marked set")
+	}
+	q := cfg.Queue
+	if q.Path != "/tmp/outbox.json" || q.Backoff != 30*time.Second || q.MaxAge != 6*time.Hour || q.MaxBackoff != time.Hour {
+		t.Fatalf("queue block not loaded: %+v", q)
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	file := t.TempDir() + "/config.yaml"
+	content := `
+quiet_hours:
+  pushover: "22:00-07:00"
+`
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if q := cfg.QuietHours["pushover"]; q.Start != 22*time.Hour || q.End != 7*time.Hour {
+		t.Fatalf("quiet hours not loaded: %+v", cfg.QuietHours)
+	}
+}
+
+func TestRoutingBlock(t *testing.T) {
+	t.Setenv("YNAB_HTTP_ADDR", ":9090")
+	file := t.TempDir() + "/config.yaml"
+	content := `
+routing:
+  critical: [pushover, exec]
+  Info: [digest]
+http_addr: ":8080"
+`
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if got := cfg.Routing["critical"]; len(got) != 2 || got[0] != "pushover" || got[1] != "exec" {
+		t.Fatalf("unexpected critical routing: %v", got)
+	}
+	if got := cfg.Routing["info"]; len(got) != 1 || got[0] != "digest" {
+		t.Fatalf("unexpected info routing: %v", got)
+	}
+	if cfg.HTTPAddr != ":9090" {
+		t.Fatalf("expected env to override http_addr, got %q", cfg.HTTPAddr)
+	}
+
+	cfg.APIToken, cfg.BudgetID, cfg.Notifier = "t", "b", "log"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	cfg.Routing["urgent"] = []string{"log"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "urgent") {
+		t.Fatalf("expected error for unknown routing severity, got %v", err)
+	}
+}
+
+func TestEscalationBlock(t *testing.T) {
+	file := t.TempDir() + "/config.yaml"
+	content := `
+escalation:
+  overdraft:
+    - after: 15m
+      notify: [pushover]
+    - after: 1h
+      notify: [exec]
+`
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	steps := cfg.Escalation["overdraft"]
+	if len(steps) != 2 || steps[0].After != 15*time.Minute || steps[1].Notify[0] != "exec" {
+		t.Fatalf("unexpected escalation policy: %+v", steps)
+	}
+
+	cfg.APIToken, cfg.BudgetID, cfg.Notifier = "t", "b", "log"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `notify channel "pushover" is not a configured notifier (log)`) {
+		t.Fatalf("expected error for unconfigured step channel, got %v", err)
+	}
+	cfg.Escalation["overdraft"][0].Notify = []string{"log"}
+	cfg.Escalation["overdraft"][1].Notify = []string{"log"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	cfg.Escalation["overdraft"][1].After = 10 * time.Minute
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected error for non-increasing step delays")
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	file := t.TempDir() + "/config.yaml"
+	if err := os.WriteFile(file, []byte("timezone: America/Chicago\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if got := cfg.Location().String(); got != "America/Chicago" {
+		t.Fatalf("expected America/Chicago, got %s", got)
+	}
+	if (Config{}).Location() != time.Local {
+		t.Fatalf("expected unset timezone to fall back to local")
+	}
+
+	cfg = Config{APIToken: "token", BudgetID: "budget", Notifier: "log", PollInterval: time.Minute, Timezone: "Mars/Olympus"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "timezone") {
+		t.Fatalf("expected timezone validation error, got %v", err)
+	}
+}
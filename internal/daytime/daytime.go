@@ -0,0 +1,64 @@
+// Package daytime parses times of day and daily time ranges shared by the
+// config and rule files.
+package daytime
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Parse converts HH:MM (24h) to a duration offset from midnight.
+func Parse(val string) (time.Duration, error) {
+	t, err := time.Parse("15:04", val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", val)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Offset returns t's offset from midnight in t's location.
+func Offset(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+}
+
+// Range is a daily time-of-day range, e.g. quiet hours. An End before Start
+// wraps past midnight (e.g. 22:00-07:00).
+type Range struct {
+	Start time.Duration // offset from midnight
+	End   time.Duration
+}
+
+// ParseRange parses "HH:MM-HH:MM".
+func ParseRange(val string) (Range, error) {
+	parts := strings.Split(strings.TrimSpace(val), "-")
+	if len(parts) != 2 {
+		return Range{}, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", val)
+	}
+	start, err := Parse(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return Range{}, err
+	}
+	end, err := Parse(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return Range{}, err
+	}
+	if start == end {
+		return Range{}, fmt.Errorf("time range %q start and end are equal", val)
+	}
+	return Range{Start: start, End: end}, nil
+}
+
+// Contains reports whether t falls inside the range.
+func (r Range) Contains(t time.Time) bool {
+	if r.Start == r.End {
+		return false
+	}
+	offset := Offset(t)
+	if r.Start < r.End {
+		return offset >= r.Start && offset < r.End
+	}
+	return offset >= r.Start || offset < r.End
+}
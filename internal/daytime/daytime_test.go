@@ -0,0 +1,32 @@
+package daytime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRange(t *testing.T) {
+	r, err := ParseRange("22:00-07:00")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	at := func(h, m int) time.Time { return time.Date(2024, time.January, 1, h, m, 0, 0, time.UTC) }
+	for _, tt := range []struct {
+		t      time.Time
+		expect bool
+	}{
+		{at(21, 59), false},
+		{at(22, 0), true},
+		{at(3, 0), true},
+		{at(7, 0), false},
+	} {
+		if got := r.Contains(tt.t); got != tt.expect {
+			t.Fatalf("%s: expected %v got %v", tt.t.Format("15:04"), tt.expect, got)
+		}
+	}
+	for _, bad := range []string{"22:00", "22:00-22:00", "25:00-07:00"} {
+		if _, err := ParseRange(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
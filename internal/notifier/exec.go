@@ -0,0 +1,97 @@
+package notifier
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const defaultExecTimeout = 30 * time.Second
+
+// ExecConfig describes a local command run once per alert.
+type ExecConfig struct {
+	Command string
+	Args    []string
+	Timeout time.Duration
+}
+
+// NewExec returns a notifier that runs a local command for each alert.
+func NewExec(cfg ExecConfig) Notifier {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultExecTimeout
+	}
+	return &ExecNotifier{cfg: cfg}
+}
+
+// ExecNotifier implements Notifier by running a command with the alert passed
+// as YNAB_ALERT_* environment variables and as JSON on stdin.
+type ExecNotifier struct {
+	cfg ExecConfig
+}
+
+func (e *ExecNotifier) Notify(ctx context.Context, subject, message string) error {
+	return e.NotifyAlert(ctx, Alert{
+		Rule:    subject,
+		Subject: subject,
+		Message: message,
+		Time:    time.Now(),
+	})
+}
+
+func (e *ExecNotifier) NotifyAlert(ctx context.Context, alert Alert) error {
+	if e.cfg.Command == "" {
+		return errors.New("exec command missing")
+	}
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, e.cfg.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(runCtx, e.cfg.Command, e.cfg.Args...)
+	cmd.Env = append(os.Environ(),
+		"YNAB_ALERT_RULE="+alert.Rule,
+		"YNAB_ALERT_SUBJECT="+alert.Subject,
+		"YNAB_ALERT_MESSAGE="+alert.Message,
+		"YNAB_ALERT_SEVERITY="+alert.Severity,
+		"YNAB_ALERT_BUDGET="+alert.Budget,
+		"YNAB_ALERT_SOURCE="+alert.Source,
+		"YNAB_ALERT_TIME="+alert.Time.Format(time.RFC3339),
+	)
+	cmd.Stdin = bytes.NewReader(payload)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+	logStderr(e.cfg.Command, stderr.String())
+	if err := ctx.Err(); err != nil {
+		// the caller gave up, not the command
+		return fmt.Errorf("exec %s: %w", e.cfg.Command, err)
+	}
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("exec %s timed out after %s", e.cfg.Command, e.cfg.Timeout)
+	}
+	if runErr != nil {
+		return fmt.Errorf("exec %s: %w", e.cfg.Command, runErr)
+	}
+	return nil
+}
+
+func logStderr(command, out string) {
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			log.Printf("exec %s stderr: %s", command, line)
+		}
+	}
+}
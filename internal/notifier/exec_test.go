@@ -0,0 +1,70 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecNotifierPassesAlert(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	script := `echo "$YNAB_ALERT_RULE|$YNAB_ALERT_MESSAGE" > "$1.env"; cat > "$1.json"`
+	n := NewExec(ExecConfig{Command: "sh", Args: []string{"-c", script, "sh", out}})
+
+	alert := Alert{
+		Rule:    "low_checking",
+		Subject: "low_checking",
+		Message: "Checking below 50",
+		Budget:  "b1",
+		Time:    time.Date(2024, time.March, 14, 9, 0, 0, 0, time.UTC),
+	}
+	if err := Send(context.Background(), n, alert); err != nil {
+		t.Fatalf("exec notify error: %v", err)
+	}
+
+	env, err := os.ReadFile(out + ".env")
+	if err != nil {
+		t.Fatalf("read env output: %v", err)
+	}
+	if got := strings.TrimSpace(string(env)); got != "low_checking|Checking below 50" {
+		t.Fatalf("unexpected env output %q", got)
+	}
+	raw, err := os.ReadFile(out + ".json")
+	if err != nil {
+		t.Fatalf("read stdin output: %v", err)
+	}
+	var decoded Alert
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("stdin was not alert JSON: %v", err)
+	}
+	if decoded.Budget != "b1" || !decoded.Time.Equal(alert.Time) {
+		t.Fatalf("unexpected alert on stdin: %+v", decoded)
+	}
+}
+
+func TestExecNotifierFailures(t *testing.T) {
+	n := NewExec(ExecConfig{Command: "sh", Args: []string{"-c", "echo boom >&2; exit 3"}})
+	if err := n.Notify(context.Background(), "r", "m"); err == nil {
+		t.Fatalf("expected error on non-zero exit")
+	}
+
+	n = NewExec(ExecConfig{Command: "sleep", Args: []string{"5"}, Timeout: 50 * time.Millisecond})
+	err := n.Notify(context.Background(), "r", "m")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	n = NewExec(ExecConfig{Command: "sleep", Args: []string{"5"}, Timeout: time.Minute})
+	err = n.Notify(ctx, "r", "m")
+	if !errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected the caller's deadline, not the command timeout, got %v", err)
+	}
+}
@@ -0,0 +1,378 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	defaultRetryBackoff    = time.Minute
+	defaultRetryMaxBackoff = time.Hour
+	defaultRetryMaxAge     = 24 * time.Hour
+)
+
+// RetryPolicy controls how failed deliveries are retried.
+type RetryPolicy struct {
+	Backoff    time.Duration // delay before the first retry; doubles per attempt
+	MaxBackoff time.Duration
+	MaxAge     time.Duration // give up (dead-letter) once a delivery has failed this long
+}
+
+// Channel is a named notifier that alerts can be routed to. When Quiet
+// reports true, deliveries to the channel are deferred instead of sent.
+type Channel struct {
+	Name     string
+	Notifier Notifier
+	Quiet    func(time.Time) bool
+}
+
+// Fanout delivers alerts to named channels. A failed delivery is queued in
+// the outbox for that channel only, so a broken channel does not block others.
+type Fanout struct {
+	channels []Channel
+	outbox   *Outbox
+	policy   RetryPolicy
+	now      func() time.Time
+}
+
+// NewFanout builds a Fanout over channels. A nil outbox disables queueing.
+func NewFanout(channels []Channel, outbox *Outbox, policy RetryPolicy) *Fanout {
+	if policy.Backoff <= 0 {
+		policy.Backoff = defaultRetryBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaultRetryMaxBackoff
+	}
+	if policy.MaxAge <= 0 {
+		policy.MaxAge = defaultRetryMaxAge
+	}
+	return &Fanout{channels: channels, outbox: outbox, policy: policy, now: time.Now}
+}
+
+// Resolve maps a rule's notify list to configured channel names. Unknown
+// names are logged and skipped; an empty list, or one naming no configured
+// channel, selects every channel so the alert is not lost.
+func (f *Fanout) Resolve(names []string) []string {
+	var out []string
+	for _, name := range names {
+		if f.channel(name) == nil {
+			log.Printf("ignoring unknown notifier channel %q", name)
+			continue
+		}
+		out = append(out, name)
+	}
+	if len(out) > 0 {
+		return out
+	}
+	if len(names) > 0 {
+		log.Printf("no notifier channel of %v is configured; sending to all channels", names)
+	}
+	for _, c := range f.channels {
+		out = append(out, c.Name)
+	}
+	return out
+}
+
+func (f *Fanout) channel(name string) Notifier {
+	if c := f.lookup(name); c != nil {
+		return c.Notifier
+	}
+	return nil
+}
+
+func (f *Fanout) lookup(name string) *Channel {
+	for i := range f.channels {
+		if f.channels[i].Name == name {
+			return &f.channels[i]
+		}
+	}
+	return nil
+}
+
+func (f *Fanout) quiet(name string, t time.Time) bool {
+	c := f.lookup(name)
+	return c != nil && c.Quiet != nil && c.Quiet(t)
+}
+
+func (f *Fanout) Notify(ctx context.Context, subject, message string) error {
+	return f.NotifyAlert(ctx, Alert{Rule: subject, Subject: subject, Message: message, Time: f.now()})
+}
+
+func (f *Fanout) NotifyAlert(ctx context.Context, alert Alert) error {
+	return f.Deliver(ctx, alert, f.Resolve(nil))
+}
+
+// Deliver sends alert to each named channel. Channels with a retry backlog
+// get the alert queued behind it; failures are queued for retry. An unreadable
+// outbox does not hold back live alerts: they are sent as if no channel had a
+// backlog. The returned error joins failures that could not be queued.
+func (f *Fanout) Deliver(ctx context.Context, alert Alert, channels []string) error {
+	backlog := map[string]bool{}
+	if f.outbox != nil {
+		pending, err := f.outbox.Pending()
+		if err != nil {
+			log.Printf("reading retry queue failed; delivering %s without it: %v", alert.Rule, err)
+		}
+		for _, q := range pending {
+			backlog[q.Channel] = true
+		}
+	}
+
+	var errs []error
+	for _, name := range channels {
+		n := f.channel(name)
+		if n == nil {
+			errs = append(errs, fmt.Errorf("unknown notifier channel %q", name))
+			continue
+		}
+		if f.outbox != nil && f.quiet(name, f.now()) {
+			if err := f.Defer(alert, []string{name}); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if backlog[name] {
+			if err := f.enqueue(name, alert, errors.New("queued behind earlier failures")); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if err := Send(ctx, n, alert); err != nil {
+			log.Printf("notify via %s failed for %s: %v", name, alert.Rule, err)
+			if f.outbox == nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+			if qerr := f.enqueue(name, alert, err); qerr != nil {
+				errs = append(errs, qerr)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f *Fanout) enqueue(channel string, alert Alert, cause error) error {
+	now := f.now()
+	q := QueuedAlert{
+		Channel:     channel,
+		Alert:       alert,
+		Pushover:    alert.Pushover,
+		Attempts:    1,
+		FirstFailed: now,
+		NextAttempt: now.Add(f.policy.Backoff),
+		LastError:   cause.Error(),
+	}
+	return f.outbox.update(func(of *outboxFile) {
+		of.Pending = append(of.Pending, q)
+	})
+}
+
+// Retry re-sends queued deliveries that are due. Each channel's queue is
+// processed in order and stops at its first failure or while the channel is
+// in quiet hours; deliveries failing for longer than the retry policy's
+// MaxAge are moved to the dead-letter list.
+func (f *Fanout) Retry(ctx context.Context) error {
+	if f.outbox == nil {
+		return nil
+	}
+	pending, err := f.outbox.Pending()
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	now := f.now()
+	blocked := map[string]bool{}
+	done := map[int]bool{}
+	var dead []QueuedAlert
+	updated := map[int]QueuedAlert{}
+	for i, q := range pending {
+		if blocked[q.Channel] || now.Before(q.NextAttempt) || f.quiet(q.Channel, now) {
+			blocked[q.Channel] = true
+			continue
+		}
+		n := f.channel(q.Channel)
+		if n == nil {
+			q.LastError = "channel no longer configured"
+			dead = append(dead, q)
+			done[i] = true
+			continue
+		}
+		alert := q.Alert
+		alert.Pushover = q.Pushover
+		sendErr := Send(ctx, n, alert)
+		if sendErr == nil {
+			log.Printf("retried %s alert via %s after %d attempt(s)", q.Alert.Rule, q.Channel, q.Attempts)
+			done[i] = true
+			continue
+		}
+		q.Attempts++
+		q.LastError = sendErr.Error()
+		if now.Sub(q.FirstFailed) >= f.policy.MaxAge {
+			log.Printf("giving up on %s alert via %s after %d attempt(s): %v", q.Alert.Rule, q.Channel, q.Attempts, sendErr)
+			dead = append(dead, q)
+			done[i] = true
+			continue
+		}
+		q.NextAttempt = now.Add(f.backoff(q.Attempts))
+		updated[i] = q
+		blocked[q.Channel] = true
+	}
+
+	// Match entries by identity rather than index: the file may have gained
+	// entries from concurrent sends since it was read.
+	return f.outbox.update(func(of *outboxFile) {
+		var keep []QueuedAlert
+		for _, cur := range of.Pending {
+			idx := indexOf(pending, cur)
+			if idx >= 0 && done[idx] {
+				continue
+			}
+			if u, ok := updated[idx]; idx >= 0 && ok {
+				cur = u
+			}
+			keep = append(keep, cur)
+		}
+		of.Pending = keep
+		of.DeadLetters = append(of.DeadLetters, dead...)
+	})
+}
+
+// Defer holds alert for the given channels until ReleaseDeferred sends it.
+// A deferred alert replaces any earlier deferred alert for the same rule and
+// channel, so only the latest state is delivered.
+func (f *Fanout) Defer(alert Alert, channels []string) error {
+	if f.outbox == nil {
+		return errors.New("deferring alerts requires an outbox")
+	}
+	now := f.now()
+	return f.outbox.update(func(of *outboxFile) {
+		for _, name := range channels {
+			q := QueuedAlert{
+				Channel:     name,
+				Alert:       alert,
+				Pushover:    alert.Pushover,
+				FirstFailed: now,
+				LastError:   "deferred by quiet hours",
+			}
+			replaced := false
+			for i, cur := range of.Deferred {
+				if cur.Channel == name && cur.Alert.Rule == alert.Rule {
+					q.FirstFailed = cur.FirstFailed
+					of.Deferred[i] = q
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				of.Deferred = append(of.Deferred, q)
+			}
+		}
+	})
+}
+
+// DropDeferred discards deferred alerts for rule, e.g. once it has resolved
+// or a newer alert supersedes them.
+func (f *Fanout) DropDeferred(rule string) error {
+	if f.outbox == nil {
+		return nil
+	}
+	return f.outbox.update(func(of *outboxFile) {
+		var keep []QueuedAlert
+		for _, q := range of.Deferred {
+			if q.Alert.Rule != rule {
+				keep = append(keep, q)
+			}
+		}
+		of.Deferred = keep
+	})
+}
+
+// ReleaseDeferred sends deferred alerts whose channel is no longer quiet and
+// for which ready reports true. Failed sends join the retry queue.
+func (f *Fanout) ReleaseDeferred(ctx context.Context, ready func(QueuedAlert) bool) error {
+	if f.outbox == nil {
+		return nil
+	}
+	deferred, err := f.outbox.Deferred()
+	if err != nil || len(deferred) == 0 {
+		return err
+	}
+
+	now := f.now()
+	var released []QueuedAlert
+	var errs []error
+	for _, q := range deferred {
+		if f.quiet(q.Channel, now) || (ready != nil && !ready(q)) {
+			continue
+		}
+		released = append(released, q)
+		alert := q.Alert
+		alert.Pushover = q.Pushover
+		if err := f.Deliver(ctx, alert, []string{q.Channel}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(released) == 0 {
+		return errors.Join(errs...)
+	}
+	errs = append(errs, f.outbox.update(func(of *outboxFile) {
+		var keep []QueuedAlert
+		for _, cur := range of.Deferred {
+			if indexOf(released, cur) < 0 {
+				keep = append(keep, cur)
+			}
+		}
+		of.Deferred = keep
+	}))
+	return errors.Join(errs...)
+}
+
+func (f *Fanout) backoff(attempts int) time.Duration {
+	d := f.policy.Backoff
+	for i := 1; i < attempts && d < f.policy.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > f.policy.MaxBackoff {
+		d = f.policy.MaxBackoff
+	}
+	return d
+}
+
+func indexOf(list []QueuedAlert, q QueuedAlert) int {
+	for i, cur := range list {
+		if cur.Channel == q.Channel && cur.Alert.Rule == q.Alert.Rule &&
+			cur.Alert.Time.Equal(q.Alert.Time) && cur.FirstFailed.Equal(q.FirstFailed) {
+			return i
+		}
+	}
+	return -1
+}
+
+// NotifyState forwards rule state to channels that track it.
+func (f *Fanout) NotifyState(ctx context.Context, rule string, firing bool) error {
+	var errs []error
+	for _, c := range f.channels {
+		if sn, ok := c.Notifier.(StateNotifier); ok {
+			if err := sn.NotifyState(ctx, rule, firing); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", c.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every channel that holds resources.
+func (f *Fanout) Close() error {
+	var errs []error
+	for _, c := range f.channels {
+		if closer, ok := c.Notifier.(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", c.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,229 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	fail bool
+	sent []string
+}
+
+func (r *recordingNotifier) Notify(_ context.Context, subject, _ string) error {
+	if r.fail {
+		return errors.New("channel down")
+	}
+	r.sent = append(r.sent, subject)
+	return nil
+}
+
+func TestFanoutQueuesPerChannelAndRetries(t *testing.T) {
+	outbox, err := OpenOutbox(t.TempDir() + "/outbox.json")
+	if err != nil {
+		t.Fatalf("outbox: %v", err)
+	}
+	good := &recordingNotifier{}
+	bad := &recordingNotifier{fail: true}
+	f := NewFanout([]Channel{{Name: "good", Notifier: good}, {Name: "bad", Notifier: bad}}, outbox, RetryPolicy{Backoff: time.Minute, MaxAge: time.Hour})
+	clock := time.Date(2024, time.March, 14, 9, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return clock }
+
+	ctx := context.Background()
+	for _, rule := range []string{"r1", "r2"} {
+		if err := f.Deliver(ctx, Alert{Rule: rule, Subject: rule, Time: clock}, []string{"good", "bad"}); err != nil {
+			t.Fatalf("deliver %s: %v", rule, err)
+		}
+	}
+	if len(good.sent) != 2 {
+		t.Fatalf("healthy channel should not be blocked, got %v", good.sent)
+	}
+	pending, _ := outbox.Pending()
+	if len(pending) != 2 || pending[0].Channel != "bad" {
+		t.Fatalf("expected 2 queued deliveries for bad channel, got %+v", pending)
+	}
+
+	// not yet due
+	if err := f.Retry(ctx); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if len(bad.sent) != 0 {
+		t.Fatalf("retry before backoff elapsed")
+	}
+
+	bad.fail = false
+	clock = clock.Add(2 * time.Minute)
+	if err := f.Retry(ctx); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if len(bad.sent) != 2 || bad.sent[0] != "r1" {
+		t.Fatalf("expected queued alerts delivered in order, got %v", bad.sent)
+	}
+	if pending, _ := outbox.Pending(); len(pending) != 0 {
+		t.Fatalf("expected empty queue, got %+v", pending)
+	}
+}
+
+func TestFanoutDeliversDespiteCorruptOutbox(t *testing.T) {
+	path := t.TempDir() + "/outbox.json"
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	outbox, err := OpenOutbox(path)
+	if err != nil {
+		t.Fatalf("outbox: %v", err)
+	}
+	good := &recordingNotifier{}
+	bad := &recordingNotifier{fail: true}
+	f := NewFanout([]Channel{{Name: "good", Notifier: good}, {Name: "bad", Notifier: bad}}, outbox, RetryPolicy{})
+
+	err = f.Deliver(context.Background(), Alert{Rule: "r1", Subject: "r1", Time: time.Now()}, []string{"good", "bad"})
+	if len(good.sent) != 1 {
+		t.Fatalf("expected the healthy channel to be sent to, got %v", good.sent)
+	}
+	if err == nil {
+		t.Fatalf("expected the failed channel's unqueueable delivery to be reported")
+	}
+}
+
+func TestFanoutDeadLettersAfterMaxAge(t *testing.T) {
+	outbox, err := OpenOutbox(t.TempDir() + "/outbox.json")
+	if err != nil {
+		t.Fatalf("outbox: %v", err)
+	}
+	bad := &recordingNotifier{fail: true}
+	f := NewFanout([]Channel{{Name: "bad", Notifier: bad}}, outbox, RetryPolicy{Backoff: time.Minute, MaxAge: 10 * time.Minute})
+	clock := time.Date(2024, time.March, 14, 9, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return clock }
+
+	ctx := context.Background()
+	if err := f.NotifyAlert(ctx, Alert{Rule: "r1", Subject: "r1", Time: clock}); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	clock = clock.Add(5 * time.Minute)
+	if err := f.Retry(ctx); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if dead, _ := outbox.DeadLetters(); len(dead) != 0 {
+		t.Fatalf("gave up too early: %+v", dead)
+	}
+	clock = clock.Add(10 * time.Minute)
+	if err := f.Retry(ctx); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	dead, _ := outbox.DeadLetters()
+	if len(dead) != 1 || dead[0].Attempts != 3 || dead[0].LastError != "channel down" {
+		t.Fatalf("expected dead letter after max age, got %+v", dead)
+	}
+	if n, err := outbox.ClearDeadLetters(); err != nil || n != 1 {
+		t.Fatalf("clear dead letters: n=%d err=%v", n, err)
+	}
+}
+
+func TestFanoutResolve(t *testing.T) {
+	f := NewFanout([]Channel{{Name: "pushover", Notifier: LogNotifier{}}, {Name: "nats", Notifier: LogNotifier{}}}, nil, RetryPolicy{})
+	if got := f.Resolve([]string{"nats", "sms"}); len(got) != 1 || got[0] != "nats" {
+		t.Fatalf("expected only configured channels, got %v", got)
+	}
+	if got := f.Resolve([]string{"log"}); len(got) != 2 {
+		t.Fatalf("expected a list of only unknown channels to select all channels, got %v", got)
+	}
+	if got := f.Resolve(nil); len(got) != 2 {
+		t.Fatalf("expected an empty list to select all channels, got %v", got)
+	}
+}
+
+func TestFanoutRetryWaitsOutQuietHours(t *testing.T) {
+	outbox, err := OpenOutbox(t.TempDir() + "/outbox.json")
+	if err != nil {
+		t.Fatalf("outbox: %v", err)
+	}
+	quiet := false
+	ch := &recordingNotifier{fail: true}
+	f := NewFanout([]Channel{{Name: "sms", Notifier: ch, Quiet: func(time.Time) bool { return quiet }}}, outbox, RetryPolicy{Backoff: time.Minute, MaxAge: time.Hour})
+	clock := time.Date(2024, time.March, 14, 21, 59, 0, 0, time.UTC)
+	f.now = func() time.Time { return clock }
+
+	ctx := context.Background()
+	if err := f.Deliver(ctx, Alert{Rule: "r1", Subject: "r1", Time: clock}, []string{"sms"}); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	ch.fail = false
+	quiet = true
+	clock = clock.Add(2 * time.Minute)
+	if err := f.Retry(ctx); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if len(ch.sent) != 0 {
+		t.Fatalf("retried during quiet hours: %v", ch.sent)
+	}
+	if pending, _ := outbox.Pending(); len(pending) != 1 || pending[0].Attempts != 1 {
+		t.Fatalf("expected delivery to stay queued untouched, got %+v", pending)
+	}
+
+	quiet = false
+	if err := f.Retry(ctx); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if len(ch.sent) != 1 {
+		t.Fatalf("expected retry once quiet hours end, got %v", ch.sent)
+	}
+}
+
+func TestFanoutDefersDuringChannelQuietHours(t *testing.T) {
+	outbox, err := OpenOutbox(t.TempDir() + "/outbox.json")
+	if err != nil {
+		t.Fatalf("outbox: %v", err)
+	}
+	quiet := true
+	sleepy := &recordingNotifier{}
+	awake := &recordingNotifier{}
+	f := NewFanout([]Channel{
+		{Name: "sleepy", Notifier: sleepy, Quiet: func(time.Time) bool { return quiet }},
+		{Name: "awake", Notifier: awake},
+	}, outbox, RetryPolicy{})
+
+	ctx := context.Background()
+	for _, msg := range []string{"first", "second"} {
+		if err := f.Deliver(ctx, Alert{Rule: "r1", Subject: msg}, []string{"sleepy", "awake"}); err != nil {
+			t.Fatalf("deliver: %v", err)
+		}
+	}
+	if len(awake.sent) != 2 || len(sleepy.sent) != 0 {
+		t.Fatalf("expected only awake channel to send, got awake=%v sleepy=%v", awake.sent, sleepy.sent)
+	}
+	if deferred, _ := outbox.Deferred(); len(deferred) != 1 || deferred[0].Alert.Subject != "second" {
+		t.Fatalf("expected one collapsed deferred alert, got %+v", deferred)
+	}
+
+	if err := f.ReleaseDeferred(ctx, nil); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if len(sleepy.sent) != 0 {
+		t.Fatalf("released while channel still quiet")
+	}
+	quiet = false
+	if err := f.ReleaseDeferred(ctx, nil); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if len(sleepy.sent) != 1 || sleepy.sent[0] != "second" {
+		t.Fatalf("expected latest deferred alert delivered, got %v", sleepy.sent)
+	}
+	if deferred, _ := outbox.Deferred(); len(deferred) != 0 {
+		t.Fatalf("expected deferred list emptied, got %+v", deferred)
+	}
+
+	quiet = true
+	if err := f.Deliver(ctx, Alert{Rule: "r2", Subject: "r2"}, []string{"sleepy"}); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if err := f.DropDeferred("r2"); err != nil {
+		t.Fatalf("drop: %v", err)
+	}
+	if deferred, _ := outbox.Deferred(); len(deferred) != 0 {
+		t.Fatalf("expected resolved alert to be dropped, got %+v", deferred)
+	}
+}
@@ -0,0 +1,185 @@
+package notifier
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	defaultMQTTTopic       = "ynab-alerts/alerts"
+	defaultMQTTStatePrefix = "ynab-alerts"
+)
+
+// MQTTConfig describes the broker connection and topics used for alerts.
+type MQTTConfig struct {
+	Broker      string // e.g. tcp://localhost:1883 or ssl://broker:8883
+	ClientID    string
+	Username    string
+	Password    string
+	Topic       string
+	QoS         byte
+	Retain      bool
+	StateTopics bool   // publish retained <StatePrefix>/<rule>/state = firing|ok
+	StatePrefix string // defaults to "ynab-alerts"
+	TLS         bool
+	CAFile      string
+	Insecure    bool // skip TLS certificate verification
+	Timeout     time.Duration
+}
+
+// MQTTNotifier implements Notifier by publishing alert JSON to an MQTT topic
+// and, optionally, retained per-rule state topics.
+type MQTTNotifier struct {
+	cfg     MQTTConfig
+	client  mqtt.Client
+	publish func(topic string, qos byte, retained bool, payload []byte) error
+}
+
+// NewMQTT connects to the broker and returns a notifier publishing there.
+func NewMQTT(cfg MQTTConfig) (*MQTTNotifier, error) {
+	if strings.TrimSpace(cfg.Broker) == "" {
+		return nil, errors.New("mqtt broker missing")
+	}
+	if cfg.QoS > 2 {
+		return nil, fmt.Errorf("mqtt qos %d is invalid (0-2)", cfg.QoS)
+	}
+	cfg = mqttDefaults(cfg)
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetConnectTimeout(cfg.Timeout).
+		SetAutoReconnect(true)
+	if cfg.Username != "" {
+		opts.SetUsername(cfg.Username)
+		opts.SetPassword(cfg.Password)
+	}
+	if cfg.TLS {
+		tlsCfg, err := mqttTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsCfg)
+	}
+
+	client := mqtt.NewClient(opts)
+	if err := waitToken(client.Connect(), cfg.Timeout); err != nil {
+		return nil, fmt.Errorf("mqtt connect: %w", err)
+	}
+	n := &MQTTNotifier{cfg: cfg, client: client}
+	n.publish = func(topic string, qos byte, retained bool, payload []byte) error {
+		return waitToken(client.Publish(topic, qos, retained, payload), cfg.Timeout)
+	}
+	return n, nil
+}
+
+func mqttDefaults(cfg MQTTConfig) MQTTConfig {
+	if cfg.ClientID == "" {
+		cfg.ClientID = "ynab-alerts"
+	}
+	if cfg.Topic == "" {
+		cfg.Topic = defaultMQTTTopic
+	}
+	if cfg.StatePrefix == "" {
+		cfg.StatePrefix = defaultMQTTStatePrefix
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return cfg
+}
+
+func mqttTLSConfig(cfg MQTTConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{InsecureSkipVerify: cfg.Insecure}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("mqtt ca file %s has no certificates", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	return tlsCfg, nil
+}
+
+func waitToken(tok mqtt.Token, timeout time.Duration) error {
+	if !tok.WaitTimeout(timeout) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return tok.Error()
+}
+
+func (m *MQTTNotifier) Notify(ctx context.Context, subject, message string) error {
+	return m.NotifyAlert(ctx, Alert{
+		Rule:    subject,
+		Subject: subject,
+		Message: message,
+		Time:    time.Now(),
+	})
+}
+
+func (m *MQTTNotifier) NotifyAlert(ctx context.Context, alert Alert) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	return m.publish(m.cfg.Topic, m.cfg.QoS, m.cfg.Retain, payload)
+}
+
+// NotifyState publishes the retained firing/ok state for a rule when state
+// topics are enabled.
+func (m *MQTTNotifier) NotifyState(ctx context.Context, rule string, firing bool) error {
+	if !m.cfg.StateTopics {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	state := "ok"
+	if firing {
+		state = "firing"
+	}
+	return m.publish(m.stateTopic(rule), m.cfg.QoS, true, []byte(state))
+}
+
+func (m *MQTTNotifier) stateTopic(rule string) string {
+	return strings.TrimSuffix(m.cfg.StatePrefix, "/") + "/" + topicLevel(rule) + "/state"
+}
+
+// Close disconnects from the broker.
+func (m *MQTTNotifier) Close() error {
+	if m.client != nil {
+		m.client.Disconnect(250)
+	}
+	return nil
+}
+
+// topicLevel makes a value safe to use as a single MQTT topic level.
+func topicLevel(v string) string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '+', '#':
+			return '_'
+		}
+		return r
+	}, v)
+}
@@ -0,0 +1,74 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type published struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  string
+}
+
+func newTestMQTT(cfg MQTTConfig) (*MQTTNotifier, *[]published) {
+	var msgs []published
+	n := &MQTTNotifier{cfg: mqttDefaults(cfg)}
+	n.publish = func(topic string, qos byte, retained bool, payload []byte) error {
+		msgs = append(msgs, published{topic, qos, retained, string(payload)})
+		return nil
+	}
+	return n, &msgs
+}
+
+func TestMQTTNotifierPublishesAlert(t *testing.T) {
+	n, msgs := newTestMQTT(MQTTConfig{QoS: 1})
+	alert := Alert{Rule: "low_checking", Subject: "low_checking", Message: "below 50", Time: time.Now()}
+	if err := Send(context.Background(), n, alert); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if len(*msgs) != 1 {
+		t.Fatalf("expected 1 publish, got %d", len(*msgs))
+	}
+	m := (*msgs)[0]
+	if m.topic != "ynab-alerts/alerts" || m.qos != 1 || m.retained {
+		t.Fatalf("unexpected publish: %+v", m)
+	}
+	var got Alert
+	if err := json.Unmarshal([]byte(m.payload), &got); err != nil || got.Message != "below 50" {
+		t.Fatalf("unexpected payload %q (%v)", m.payload, err)
+	}
+}
+
+func TestMQTTNotifierStateTopics(t *testing.T) {
+	n, msgs := newTestMQTT(MQTTConfig{})
+	if err := n.NotifyState(context.Background(), "r", true); err != nil {
+		t.Fatalf("state: %v", err)
+	}
+	if len(*msgs) != 0 {
+		t.Fatalf("state topics disabled; expected no publish, got %+v", *msgs)
+	}
+
+	n, msgs = newTestMQTT(MQTTConfig{StateTopics: true, StatePrefix: "home/ynab/"})
+	if err := n.NotifyState(context.Background(), "cards/main", true); err != nil {
+		t.Fatalf("state: %v", err)
+	}
+	if err := n.NotifyState(context.Background(), "savings", false); err != nil {
+		t.Fatalf("state: %v", err)
+	}
+	want := []published{
+		{"home/ynab/cards_main/state", 0, true, "firing"},
+		{"home/ynab/savings/state", 0, true, "ok"},
+	}
+	if len(*msgs) != len(want) {
+		t.Fatalf("expected %d publishes, got %+v", len(want), *msgs)
+	}
+	for i, w := range want {
+		if (*msgs)[i] != w {
+			t.Fatalf("publish %d: expected %+v got %+v", i, w, (*msgs)[i])
+		}
+	}
+}
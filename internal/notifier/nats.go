@@ -0,0 +1,136 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const defaultNATSSubject = "ynab-alerts.alerts.{{.Rule}}"
+
+// NATSConfig describes where alerts are published on NATS.
+type NATSConfig struct {
+	URL       string
+	Subject   string // text/template with .Rule and .Budget
+	JetStream bool
+	Timeout   time.Duration
+}
+
+// NATSNotifier implements Notifier by publishing alert JSON to a NATS subject,
+// optionally through JetStream with acknowledgements and de-duplication.
+type NATSNotifier struct {
+	cfg     NATSConfig
+	subject *template.Template
+	nc      *nats.Conn
+	js      nats.JetStreamContext
+}
+
+// NewNATS connects to NATS and returns a notifier publishing alerts there.
+func NewNATS(cfg NATSConfig) (*NATSNotifier, error) {
+	if strings.TrimSpace(cfg.URL) == "" {
+		return nil, errors.New("nats url missing")
+	}
+	if strings.TrimSpace(cfg.Subject) == "" {
+		cfg.Subject = defaultNATSSubject
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	tmpl, err := template.New("subject").Option("missingkey=error").Parse(cfg.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("nats subject template: %w", err)
+	}
+
+	nc, err := nats.Connect(cfg.URL, nats.Name("ynab-alerts notifier"))
+	if err != nil {
+		return nil, err
+	}
+	n := &NATSNotifier{cfg: cfg, subject: tmpl, nc: nc}
+	if cfg.JetStream {
+		js, err := nc.JetStream()
+		if err != nil {
+			nc.Close()
+			return nil, err
+		}
+		n.js = js
+	}
+	return n, nil
+}
+
+func (n *NATSNotifier) Notify(ctx context.Context, subject, message string) error {
+	return n.NotifyAlert(ctx, Alert{
+		Rule:    subject,
+		Subject: subject,
+		Message: message,
+		Time:    time.Now(),
+	})
+}
+
+func (n *NATSNotifier) NotifyAlert(ctx context.Context, alert Alert) error {
+	subject, err := n.subjectFor(alert)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(subject)
+	msg.Data = payload
+
+	pubCtx, cancel := context.WithTimeout(ctx, n.cfg.Timeout)
+	defer cancel()
+	if n.js == nil {
+		if err := n.nc.PublishMsg(msg); err != nil {
+			return err
+		}
+		return n.nc.FlushWithContext(pubCtx)
+	}
+	_, err = n.js.PublishMsg(msg, nats.Context(pubCtx), nats.MsgId(alertMsgID(alert)))
+	return err
+}
+
+// Close drains the underlying NATS connection.
+func (n *NATSNotifier) Close() error {
+	return n.nc.Drain()
+}
+
+func (n *NATSNotifier) subjectFor(alert Alert) (string, error) {
+	var buf bytes.Buffer
+	data := map[string]string{
+		"Rule":   subjectToken(alert.Rule),
+		"Budget": subjectToken(alert.Budget),
+	}
+	if err := n.subject.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("nats subject: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// subjectToken makes a value safe to use as a single NATS subject token.
+func subjectToken(v string) string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\n', '\r':
+			return '_'
+		}
+		return r
+	}, v)
+}
+
+// alertMsgID identifies an alert for JetStream de-duplication so retries of
+// the same alert are stored once.
+func alertMsgID(alert Alert) string {
+	return fmt.Sprintf("%s:%s:%d", alert.Budget, alert.Rule, alert.Time.Unix())
+}
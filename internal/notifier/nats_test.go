@@ -0,0 +1,111 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func runNATSServer(t *testing.T, jetstream bool) *server.Server {
+	t.Helper()
+	opts := &server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true}
+	if jetstream {
+		opts.JetStream = true
+		opts.StoreDir = t.TempDir()
+	}
+	srv, err := server.NewServer(opts)
+	if err != nil {
+		t.Fatalf("nats server: %v", err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatalf("nats server not ready")
+	}
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+func TestNATSNotifierPublishesAlert(t *testing.T) {
+	srv := runNATSServer(t, false)
+
+	sub, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer sub.Close()
+	msgs := make(chan *nats.Msg, 1)
+	if _, err := sub.ChanSubscribe("alerts.>", msgs); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := sub.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	n, err := NewNATS(NATSConfig{URL: srv.ClientURL(), Subject: "alerts.{{.Budget}}.{{.Rule}}"})
+	if err != nil {
+		t.Fatalf("nats notifier: %v", err)
+	}
+	defer n.Close()
+
+	alert := Alert{Rule: "low checking", Subject: "low checking", Message: "below 50", Budget: "b1", Time: time.Now()}
+	if err := Send(context.Background(), n, alert); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case m := <-msgs:
+		if m.Subject != "alerts.b1.low_checking" {
+			t.Fatalf("unexpected subject %q", m.Subject)
+		}
+		var got Alert
+		if err := json.Unmarshal(m.Data, &got); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if got.Rule != "low checking" || got.Message != "below 50" {
+			t.Fatalf("unexpected payload: %+v", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no alert received")
+	}
+}
+
+func TestNATSNotifierJetStreamDedup(t *testing.T) {
+	srv := runNATSServer(t, true)
+
+	nc, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer nc.Close()
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatalf("jetstream: %v", err)
+	}
+	if _, err := js.AddStream(&nats.StreamConfig{Name: "ALERTS", Subjects: []string{"ynab-alerts.alerts.>"}}); err != nil {
+		t.Fatalf("add stream: %v", err)
+	}
+
+	n, err := NewNATS(NATSConfig{URL: srv.ClientURL(), JetStream: true})
+	if err != nil {
+		t.Fatalf("nats notifier: %v", err)
+	}
+	defer n.Close()
+
+	alert := Alert{Rule: "r1", Subject: "r1", Message: "m", Time: time.Unix(1700000000, 0)}
+	for i := 0; i < 2; i++ {
+		if err := n.NotifyAlert(context.Background(), alert); err != nil {
+			t.Fatalf("publish %d: %v", i, err)
+		}
+	}
+	info, err := js.StreamInfo("ALERTS")
+	if err != nil {
+		t.Fatalf("stream info: %v", err)
+	}
+	if info.State.Msgs != 1 {
+		t.Fatalf("expected duplicate alert to be dropped, stream has %d msgs", info.State.Msgs)
+	}
+}
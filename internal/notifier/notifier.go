@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 )
 
 // Notifier dispatches alert messages to an output channel.
@@ -12,10 +13,46 @@ type Notifier interface {
 	Notify(ctx context.Context, subject, message string) error
 }
 
+// Alert carries structured details about a fired rule.
+type Alert struct {
+	Rule     string    `json:"rule"`
+	Subject  string    `json:"subject"`
+	Message  string    `json:"message"`
+	Severity string    `json:"severity,omitempty"`
+	Budget   string    `json:"budget,omitempty"`
+	Source   string    `json:"source,omitempty"` // rule file position of the clause that fired, e.g. "cards.yaml:12:5"
+	Time     time.Time `json:"time"`
+
+	// Pushover holds per-rule Pushover options; other notifiers ignore it.
+	Pushover *PushoverParams `json:"-"`
+}
+
+// AlertNotifier is implemented by notifiers that can use structured alert details.
+type AlertNotifier interface {
+	NotifyAlert(ctx context.Context, alert Alert) error
+}
+
+// Send delivers alert via n, preferring structured delivery when supported.
+func Send(ctx context.Context, n Notifier, alert Alert) error {
+	if an, ok := n.(AlertNotifier); ok {
+		return an.NotifyAlert(ctx, alert)
+	}
+	return n.Notify(ctx, alert.Subject, alert.Message)
+}
+
+// StateNotifier is implemented by notifiers that publish the current firing/ok
+// state of each evaluated rule.
+type StateNotifier interface {
+	NotifyState(ctx context.Context, rule string, firing bool) error
+}
+
 // Options selects the notifier implementation.
 type Options struct {
 	Kind     string
 	Pushover PushoverConfig
+	Exec     ExecConfig
+	NATS     NATSConfig
+	MQTT     MQTTConfig
 }
 
 // Build constructs a notifier based on the configured kind.
@@ -28,6 +65,23 @@ func Build(opts Options) (Notifier, error) {
 		return NewPushover(opts.Pushover), nil
 	case "log":
 		return LogNotifier{}, nil
+	case "exec":
+		if opts.Exec.Command == "" {
+			return nil, errors.New("exec notifier selected but command missing")
+		}
+		return NewExec(opts.Exec), nil
+	case "nats":
+		n, err := NewNATS(opts.NATS)
+		if err != nil {
+			return nil, err // not a typed nil *NATSNotifier
+		}
+		return n, nil
+	case "mqtt":
+		n, err := NewMQTT(opts.MQTT)
+		if err != nil {
+			return nil, err
+		}
+		return n, nil
 	default:
 		return nil, fmt.Errorf("unknown notifier kind %q", opts.Kind)
 	}
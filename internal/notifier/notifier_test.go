@@ -25,3 +25,36 @@ func TestBuildUnknown(t *testing.T) {
 		t.Fatalf("expected error on unknown notifier kind")
 	}
 }
+
+func TestBuildExecRequiresCommand(t *testing.T) {
+	if _, err := Build(Options{Kind: "exec"}); err == nil {
+		t.Fatalf("expected error when exec command missing")
+	}
+	n, err := Build(Options{Kind: "exec", Exec: ExecConfig{Command: "true"}})
+	if err != nil {
+		t.Fatalf("expected exec notifier, got error: %v", err)
+	}
+	if _, ok := n.(*ExecNotifier); !ok {
+		t.Fatalf("expected *ExecNotifier, got %T", n)
+	}
+}
+
+func TestBuildNATSErrorReturnsNilNotifier(t *testing.T) {
+	n, err := Build(Options{Kind: "nats"})
+	if err == nil {
+		t.Fatalf("expected error when nats url missing")
+	}
+	if n != nil {
+		t.Fatalf("expected untyped nil notifier on error, got %#v", n)
+	}
+}
+
+func TestBuildMQTTErrorReturnsNilNotifier(t *testing.T) {
+	n, err := Build(Options{Kind: "mqtt"})
+	if err == nil {
+		t.Fatalf("expected error when mqtt broker missing")
+	}
+	if n != nil {
+		t.Fatalf("expected untyped nil notifier on error, got %#v", n)
+	}
+}
@@ -0,0 +1,120 @@
+package notifier
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ynab-alerts/internal/statefile"
+)
+
+// QueuedAlert is a delivery to one channel that failed and awaits retry (or
+// was given up on and moved to the dead-letter list), or that was deferred
+// until quiet hours end.
+type QueuedAlert struct {
+	Channel     string          `json:"channel"`
+	Alert       Alert           `json:"alert"`
+	Pushover    *PushoverParams `json:"pushover,omitempty"`
+	Attempts    int             `json:"attempts"`
+	FirstFailed time.Time       `json:"first_failed"`
+	NextAttempt time.Time       `json:"next_attempt"`
+	LastError   string          `json:"last_error"`
+}
+
+// Outbox persists failed deliveries and dead letters as JSON. Every operation
+// re-reads the file, and updates hold a file lock, so edits made by other
+// processes (e.g. the CLI clearing dead letters while the daemon runs) are
+// not overwritten.
+type Outbox struct {
+	path string
+	mu   sync.Mutex
+}
+
+type outboxFile struct {
+	Pending     []QueuedAlert `json:"pending"`
+	Deferred    []QueuedAlert `json:"deferred,omitempty"`
+	DeadLetters []QueuedAlert `json:"dead_letters"`
+}
+
+// OpenOutbox returns an Outbox persisted at path, creating its directory.
+func OpenOutbox(path string) (*Outbox, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return &Outbox{path: path}, nil
+}
+
+// Pending returns queued deliveries awaiting retry.
+func (o *Outbox) Pending() ([]QueuedAlert, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	f, err := o.read()
+	return f.Pending, err
+}
+
+// Deferred returns deliveries held back by quiet hours.
+func (o *Outbox) Deferred() ([]QueuedAlert, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	f, err := o.read()
+	return f.Deferred, err
+}
+
+// DeadLetters returns deliveries that were given up on.
+func (o *Outbox) DeadLetters() ([]QueuedAlert, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	f, err := o.read()
+	return f.DeadLetters, err
+}
+
+// ClearDeadLetters drops all dead letters and reports how many were removed.
+func (o *Outbox) ClearDeadLetters() (int, error) {
+	var n int
+	err := o.update(func(f *outboxFile) {
+		n = len(f.DeadLetters)
+		f.DeadLetters = nil
+	})
+	return n, err
+}
+
+// update applies fn to the on-disk state and writes it back. The file lock
+// keeps another process from writing between the read and the write, and the
+// write replaces the file atomically.
+func (o *Outbox) update(fn func(*outboxFile)) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	unlock, err := statefile.Lock(o.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f, err := o.read()
+	if err != nil {
+		return err
+	}
+	fn(&f)
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return statefile.Write(o.path, data)
+}
+
+func (o *Outbox) read() (outboxFile, error) {
+	var f outboxFile
+	data, err := os.ReadFile(o.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return f, nil
+		}
+		return f, err
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return f, err
+	}
+	return f, nil
+}
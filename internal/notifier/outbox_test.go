@@ -0,0 +1,52 @@
+package notifier
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOutboxUpdatesAcrossHandles(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/outbox.json"
+	// separate handles stand in for the daemon and CLI processes, which share
+	// only the file
+	const writers = 8
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		o, err := OpenOutbox(path)
+		if err != nil {
+			t.Fatalf("outbox: %v", err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := o.update(func(of *outboxFile) {
+				of.DeadLetters = append(of.DeadLetters, QueuedAlert{Channel: fmt.Sprint(i), FirstFailed: time.Now()})
+			})
+			if err != nil {
+				t.Errorf("update: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	o, err := OpenOutbox(path)
+	if err != nil {
+		t.Fatalf("outbox: %v", err)
+	}
+	dead, err := o.DeadLetters()
+	if err != nil || len(dead) != writers {
+		t.Fatalf("expected %d dead letters, got %d (%v)", writers, len(dead), err)
+	}
+	if n, err := o.ClearDeadLetters(); err != nil || n != writers {
+		t.Fatalf("clear: n=%d err=%v", n, err)
+	}
+	// only the outbox and its lock remain; no temp files are left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("unexpected files: %v (%v)", entries, err)
+	}
+}
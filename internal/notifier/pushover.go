@@ -2,20 +2,48 @@ package notifier
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	defaultPushoverRetry  = time.Minute
+	defaultPushoverExpire = time.Hour
+)
+
 // PushoverConfig holds credentials for Pushover notifications.
 type PushoverConfig struct {
 	AppToken string
 	UserKey  string
 	Device   string
 	Endpt    string
+	// ReceiptEndpt is the receipts API base used to poll emergency alerts.
+	ReceiptEndpt string
+	ReceiptPoll  time.Duration
+	// OnAcknowledge is called when an emergency alert's receipt reports it
+	// was acknowledged on a device.
+	OnAcknowledge func(rule, by string, at time.Time)
+}
+
+// PushoverParams carries per-alert Pushover delivery options.
+type PushoverParams struct {
+	Priority int // -2..2; 2 is emergency and requires acknowledgement
+	Retry    time.Duration
+	Expire   time.Duration
+	Sound    string
+	URL      string
+	URLTitle string
+	HTML     bool
+	TTL      time.Duration
 }
 
 // NewPushover returns a Pushover notifier.
@@ -23,9 +51,18 @@ func NewPushover(cfg PushoverConfig) Notifier {
 	if cfg.Endpt == "" {
 		cfg.Endpt = "https://api.pushover.net/1/messages.json"
 	}
+	if cfg.ReceiptEndpt == "" {
+		cfg.ReceiptEndpt = "https://api.pushover.net/1/receipts"
+	}
+	if cfg.ReceiptPoll <= 0 {
+		cfg.ReceiptPoll = 30 * time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
 	return &PushoverNotifier{
 		cfg:    cfg,
 		client: &http.Client{Timeout: 10 * time.Second},
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
 
@@ -33,9 +70,30 @@ func NewPushover(cfg PushoverConfig) Notifier {
 type PushoverNotifier struct {
 	cfg    PushoverConfig
 	client *http.Client
+
+	// receipt pollers outlive individual sends and stop on Close.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// pushoverResponse is the JSON body returned by the messages and receipts APIs.
+type pushoverResponse struct {
+	Status         int      `json:"status"`
+	Request        string   `json:"request"`
+	Receipt        string   `json:"receipt"`
+	Errors         []string `json:"errors"`
+	Acknowledged   int      `json:"acknowledged"`
+	AcknowledgedAt int64    `json:"acknowledged_at"`
+	AcknowledgedBy string   `json:"acknowledged_by_device"`
+	Expired        int      `json:"expired"`
 }
 
 func (p *PushoverNotifier) Notify(ctx context.Context, subject, message string) error {
+	return p.NotifyAlert(ctx, Alert{Rule: subject, Subject: subject, Message: message})
+}
+
+func (p *PushoverNotifier) NotifyAlert(ctx context.Context, alert Alert) error {
 	if p.cfg.AppToken == "" || p.cfg.UserKey == "" {
 		return errors.New("pushover credentials missing")
 	}
@@ -43,11 +101,16 @@ func (p *PushoverNotifier) Notify(ctx context.Context, subject, message string)
 	form := url.Values{}
 	form.Set("token", p.cfg.AppToken)
 	form.Set("user", p.cfg.UserKey)
-	form.Set("title", subject)
-	form.Set("message", message)
+	form.Set("title", alert.Subject)
+	form.Set("message", alert.Message)
 	if p.cfg.Device != "" {
 		form.Set("device", p.cfg.Device)
 	}
+	params := PushoverParams{}
+	if alert.Pushover != nil {
+		params = *alert.Pushover
+	}
+	setPushoverParams(form, params)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.Endpt, strings.NewReader(form.Encode()))
 	if err != nil {
@@ -55,14 +118,131 @@ func (p *PushoverNotifier) Notify(ctx context.Context, subject, message string)
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := p.client.Do(req)
+	body, err := p.do(req)
 	if err != nil {
 		return err
 	}
+	if params.Priority == 2 && body.Receipt != "" && p.cfg.OnAcknowledge != nil {
+		expire := params.Expire
+		if expire <= 0 {
+			expire = defaultPushoverExpire
+		}
+		p.wg.Add(1)
+		go p.pollReceipt(alert.Rule, body.Receipt, expire)
+	}
+	return nil
+}
+
+func setPushoverParams(form url.Values, params PushoverParams) {
+	if params.Priority != 0 {
+		form.Set("priority", strconv.Itoa(params.Priority))
+	}
+	if params.Priority == 2 {
+		retry, expire := params.Retry, params.Expire
+		if retry <= 0 {
+			retry = defaultPushoverRetry
+		}
+		if expire <= 0 {
+			expire = defaultPushoverExpire
+		}
+		form.Set("retry", strconv.Itoa(int(retry.Seconds())))
+		form.Set("expire", strconv.Itoa(int(expire.Seconds())))
+	}
+	if params.Sound != "" {
+		form.Set("sound", params.Sound)
+	}
+	if params.URL != "" {
+		form.Set("url", params.URL)
+		if params.URLTitle != "" {
+			form.Set("url_title", params.URLTitle)
+		}
+	}
+	if params.HTML {
+		form.Set("html", "1")
+	}
+	if params.TTL > 0 {
+		form.Set("ttl", strconv.Itoa(int(params.TTL.Seconds())))
+	}
+}
+
+// do sends req and decodes the Pushover JSON response, surfacing API errors.
+func (p *PushoverNotifier) do(req *http.Request) (pushoverResponse, error) {
+	var body pushoverResponse
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return body, err
+	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode >= 300 {
-		return fmt.Errorf("pushover returned status %s", resp.Status)
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return body, fmt.Errorf("pushover response (status %d): %w", resp.StatusCode, err)
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return body, fmt.Errorf("pushover response (status %d) is not JSON: %v: %q", resp.StatusCode, err, snippet(raw))
 	}
+	if resp.StatusCode >= 300 || body.Status != 1 {
+		if len(body.Errors) > 0 {
+			return body, fmt.Errorf("pushover returned status %d: %s", resp.StatusCode, strings.Join(body.Errors, "; "))
+		}
+		return body, fmt.Errorf("pushover returned status %d: %q", resp.StatusCode, snippet(raw))
+	}
+	return body, nil
+}
+
+// snippet shortens a response body for error messages.
+func snippet(raw []byte) string {
+	const max = 200
+	s := strings.TrimSpace(string(raw))
+	if len(s) > max {
+		return s[:max] + "..."
+	}
+	return s
+}
+
+// pollReceipt checks an emergency alert's receipt until it is acknowledged,
+// expires, or the notifier is closed.
+func (p *PushoverNotifier) pollReceipt(rule, receipt string, expire time.Duration) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cfg.ReceiptPoll)
+	defer ticker.Stop()
+	deadline := time.NewTimer(expire + p.cfg.ReceiptPoll)
+	defer deadline.Stop()
+
+	endpoint := fmt.Sprintf("%s/%s.json?token=%s", strings.TrimSuffix(p.cfg.ReceiptEndpt, "/"), url.PathEscape(receipt), url.QueryEscape(p.cfg.AppToken))
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-ticker.C:
+		}
+
+		req, err := http.NewRequestWithContext(p.ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			log.Printf("pushover receipt %s: %v", receipt, err)
+			return
+		}
+		body, err := p.do(req)
+		if err != nil {
+			log.Printf("pushover receipt %s: %v", receipt, err)
+			continue
+		}
+		if body.Acknowledged == 1 {
+			p.cfg.OnAcknowledge(rule, body.AcknowledgedBy, time.Unix(body.AcknowledgedAt, 0))
+			return
+		}
+		if body.Expired == 1 {
+			return
+		}
+	}
+}
+
+// Close stops any outstanding receipt pollers.
+func (p *PushoverNotifier) Close() error {
+	p.cancel()
+	p.wg.Wait()
 	return nil
 }
@@ -0,0 +1,119 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPushoverSendsParamsAndPollsReceipt(t *testing.T) {
+	var polls int32
+	var form map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/messages.json", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		form = map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		fmt.Fprint(w, `{"status":1,"request":"req1","receipt":"rcpt1"}`)
+	})
+	mux.HandleFunc("/receipts/rcpt1.json", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&polls, 1) < 2 {
+			fmt.Fprint(w, `{"status":1,"acknowledged":0}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"acknowledged":1,"acknowledged_at":1710406800,"acknowledged_by_device":"phone"}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	acked := make(chan string, 1)
+	n := NewPushover(PushoverConfig{
+		AppToken:     "app",
+		UserKey:      "user",
+		Endpt:        srv.URL + "/messages.json",
+		ReceiptEndpt: srv.URL + "/receipts",
+		ReceiptPoll:  10 * time.Millisecond,
+		OnAcknowledge: func(rule, by string, at time.Time) {
+			acked <- rule + "@" + by
+		},
+	})
+	defer n.(*PushoverNotifier).Close()
+
+	alert := Alert{
+		Rule:    "overdraft",
+		Subject: "overdraft",
+		Message: "<b>Checking</b> low",
+		Pushover: &PushoverParams{
+			Priority: 2,
+			Retry:    45 * time.Second,
+			Sound:    "siren",
+			URL:      "https://app.ynab.com/b1/budget",
+			URLTitle: "Open budget",
+			HTML:     true,
+			TTL:      time.Hour,
+		},
+	}
+	if err := Send(context.Background(), n, alert); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	want := map[string]string{
+		"priority":  "2",
+		"retry":     "45",
+		"expire":    "3600",
+		"sound":     "siren",
+		"url":       "https://app.ynab.com/b1/budget",
+		"url_title": "Open budget",
+		"html":      "1",
+		"ttl":       "3600",
+	}
+	for k, v := range want {
+		if form[k] != v {
+			t.Fatalf("form %s: expected %q got %q", k, v, form[k])
+		}
+	}
+
+	select {
+	case got := <-acked:
+		if got != "overdraft@phone" {
+			t.Fatalf("unexpected acknowledgement %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("receipt acknowledgement not reported")
+	}
+}
+
+func TestPushoverSurfacesAPIErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"status":0,"errors":["user identifier is invalid"],"request":"req2"}`)
+	}))
+	defer srv.Close()
+
+	n := NewPushover(PushoverConfig{AppToken: "app", UserKey: "bad", Endpt: srv.URL})
+	err := n.Notify(context.Background(), "s", "m")
+	if err == nil || !strings.Contains(err.Error(), "user identifier is invalid") {
+		t.Fatalf("expected API error to be surfaced, got %v", err)
+	}
+}
+
+func TestPushoverReportsUndecodableResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html>maintenance</html>")
+	}))
+	defer srv.Close()
+
+	n := NewPushover(PushoverConfig{AppToken: "app", UserKey: "u", Endpt: srv.URL})
+	err := n.Notify(context.Background(), "s", "m")
+	if err == nil || !strings.Contains(err.Error(), "status 200") || !strings.Contains(err.Error(), "maintenance") {
+		t.Fatalf("expected decode error with status and body, got %v", err)
+	}
+}
@@ -0,0 +1,492 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/file"
+)
+
+// AccountInfo describes an account for the accounts.* aggregates.
+type AccountInfo struct {
+	Type     string // YNAB account type, e.g. "checking" or "creditCard"
+	OnBudget bool
+	Closed   bool // only matched by a closed filter
+	Deleted  bool // never matched
+}
+
+// accountTypes are the account types YNAB reports.
+var accountTypes = []string{
+	"checking", "savings", "cash", "creditCard", "lineOfCredit", "otherAsset",
+	"otherLiability", "mortgage", "autoLoan", "studentLoan", "personalLoan",
+	"medicalDebt", "otherDebt",
+}
+
+// accountsFuncs aggregate balances over the accounts matching filters such
+// as {type: "creditCard", on_budget: true, match: "Savings*"}; expressions
+// may also write them as named arguments, accounts.sum(type="creditCard").
+// Every aggregate of no accounts is 0; count tells an empty match apart.
+type accountsFuncs struct {
+	Sum   func(...map[string]interface{}) (float64, error) `expr:"sum"`
+	Total func(...map[string]interface{}) (float64, error) `expr:"total"` // alias of sum
+	Min   func(...map[string]interface{}) (float64, error) `expr:"min"`
+	Max   func(...map[string]interface{}) (float64, error) `expr:"max"`
+	Count func(...map[string]interface{}) (int, error)     `expr:"count"`
+}
+
+// accountFilterKeys are the filters the accounts.* functions accept.
+var accountFilterKeys = []string{"type", "on_budget", "closed", "match"}
+
+func buildAccountsFuncs(data Data) accountsFuncs {
+	// balances of the matching accounts, in dollars
+	balances := func(filters []map[string]interface{}) ([]float64, error) {
+		var out []float64
+		for name, bal := range data.Accounts {
+			ok, err := matchAccount(name, data.AccountInfo[name], filters)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				out = append(out, float64(bal)/1000)
+			}
+		}
+		return out, nil
+	}
+	sum := func(filters ...map[string]interface{}) (float64, error) {
+		vals, err := balances(filters)
+		total := 0.0
+		for _, v := range vals {
+			total += v
+		}
+		return total, err
+	}
+	extreme := func(pick func(a, b float64) float64) func(...map[string]interface{}) (float64, error) {
+		return func(filters ...map[string]interface{}) (float64, error) {
+			vals, err := balances(filters)
+			if err != nil || len(vals) == 0 {
+				return 0, err
+			}
+			out := vals[0]
+			for _, v := range vals[1:] {
+				out = pick(out, v)
+			}
+			return out, nil
+		}
+	}
+	return accountsFuncs{
+		Sum:   sum,
+		Total: sum,
+		Min:   extreme(math.Min),
+		Max:   extreme(math.Max),
+		Count: func(filters ...map[string]interface{}) (int, error) {
+			vals, err := balances(filters)
+			return len(vals), err
+		},
+	}
+}
+
+// matchAccount reports whether the account passes every filter. Deleted
+// accounts never match, and closed ones only when a filter asks for them.
+func matchAccount(name string, info AccountInfo, filters []map[string]interface{}) (bool, error) {
+	if info.Deleted {
+		return false, nil
+	}
+	closed := false
+	for _, f := range filters {
+		for key, want := range f {
+			switch key {
+			case "type":
+				s, ok := want.(string)
+				if !ok {
+					return false, fmt.Errorf("accounts filter type must be a string, got %T", want)
+				}
+				if !strings.EqualFold(info.Type, s) {
+					return false, nil
+				}
+			case "on_budget":
+				b, ok := want.(bool)
+				if !ok {
+					return false, fmt.Errorf("accounts filter on_budget must be a boolean, got %T", want)
+				}
+				if info.OnBudget != b {
+					return false, nil
+				}
+			case "closed":
+				b, ok := want.(bool)
+				if !ok {
+					return false, fmt.Errorf("accounts filter closed must be a boolean, got %T", want)
+				}
+				if info.Closed != b {
+					return false, nil
+				}
+				closed = b
+			case "match":
+				s, ok := want.(string)
+				if !ok {
+					return false, fmt.Errorf("accounts filter match must be a string, got %T", want)
+				}
+				matched, err := globMatch(s, name)
+				if err != nil {
+					return false, fmt.Errorf("accounts filter match %q: %v", s, err)
+				}
+				if !matched {
+					return false, nil
+				}
+			default:
+				return false, fmt.Errorf("unknown accounts filter %q (type|on_budget|closed|match)", key)
+			}
+		}
+	}
+	return closed || !info.Closed, nil
+}
+
+// globMatch reports whether name matches the glob pattern. Unlike
+// path.Match, * and ? match any character, "/" included, since account
+// names are not paths: "Savings*" matches "Savings/Trip".
+func globMatch(pattern, name string) (bool, error) {
+	re, err := globPattern(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(name), nil
+}
+
+// globPatterns caches compiled globs, as a match filter is checked against
+// every account on every evaluation.
+var globPatterns sync.Map // pattern -> *regexp.Regexp
+
+// globPattern compiles a glob of * (any run of characters), ? (any one),
+// [a-z] or [!a-z] classes and \-escapes into an anchored regexp.
+func globPattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := globPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+	rs := []rune(pattern)
+	for i := 0; i < len(rs); i++ {
+		switch rs[i] {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		case '\\':
+			if i+1 == len(rs) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(string(rs[i])))
+		case '[':
+			end, err := globClass(rs, i, &b)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(rs[i])))
+		}
+	}
+	b.WriteString(`$`)
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, errors.New("bad character class")
+	}
+	globPatterns.Store(pattern, re)
+	return re, nil
+}
+
+// globClass writes the character class opening at rs[start] to b and
+// returns the index of its closing bracket.
+func globClass(rs []rune, start int, b *strings.Builder) (int, error) {
+	i := start + 1
+	b.WriteByte('[')
+	if i < len(rs) && (rs[i] == '!' || rs[i] == '^') {
+		b.WriteByte('^')
+		i++
+	}
+	first := i
+	for ; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == ']' && i > first:
+			b.WriteByte(']')
+			return i, nil
+		case r == '-' && i > first && i+1 < len(rs) && rs[i+1] != ']':
+			b.WriteByte('-') // a range such as a-z
+			continue
+		case r == '\\' && i+1 < len(rs):
+			i++
+			r = rs[i]
+		}
+		if strings.ContainsRune(`[]\-^`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return 0, errors.New("unclosed character class")
+}
+
+// namedArgs rewrites named arguments of accounts.* calls into the filter map
+// expr understands: accounts.sum(type="creditCard") becomes
+// accounts.sum({type:"creditCard"}). It returns the rune offsets of the
+// inserted braces so error columns can be mapped back to src.
+func namedArgs(src string) (string, []int) {
+	if !strings.Contains(src, "accounts.") || !strings.Contains(src, "=") {
+		return src, nil
+	}
+	rs := []rune(src)
+	out := make([]rune, 0, len(rs)+4)
+	var inserts []int
+	for i := 0; i < len(rs); {
+		if isQuote(rs[i]) {
+			end := skipString(rs, i)
+			out = append(out, rs[i:end]...)
+			i = end
+			continue
+		}
+		if open, ok := accountsCallAt(rs, i); ok {
+			if end := closingParen(rs, open); end > 0 && startsNamed(rs[open+1:end]) {
+				out = append(out, rs[i:open+1]...)
+				inserts = append(inserts, len(out))
+				out = append(out, '{')
+				out = append(out, colonize(rs[open+1:end])...)
+				inserts = append(inserts, len(out))
+				out = append(out, '}', ')')
+				i = end + 1
+				continue
+			}
+		}
+		out = append(out, rs[i])
+		i++
+	}
+	return string(out), inserts
+}
+
+// accountsCallAt reports whether an accounts.<name>( call starts at i,
+// returning the index of its opening parenthesis.
+func accountsCallAt(rs []rune, i int) (int, bool) {
+	const prefix = "accounts."
+	if !strings.HasPrefix(string(rs[i:]), prefix) || (i > 0 && isIdentRune(rs[i-1])) {
+		return 0, false
+	}
+	j := i + len(prefix)
+	for j < len(rs) && isIdentRune(rs[j]) {
+		j++
+	}
+	for j < len(rs) && rs[j] == ' ' {
+		j++
+	}
+	if j == len(rs) || rs[j] != '(' {
+		return 0, false
+	}
+	return j, true
+}
+
+// closingParen returns the index of the parenthesis closing the one at open,
+// or -1.
+func closingParen(rs []rune, open int) int {
+	depth := 0
+	for i := open; i < len(rs); i++ {
+		switch {
+		case isQuote(rs[i]):
+			i = skipString(rs, i) - 1
+		case rs[i] == '(' || rs[i] == '[' || rs[i] == '{':
+			depth++
+		case rs[i] == ')' || rs[i] == ']' || rs[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// startsNamed reports whether args begins with "name =" (not "==").
+func startsNamed(args []rune) bool {
+	_, ok := namedEquals(args, 0)
+	return ok
+}
+
+// namedEquals finds the "=" of a "name =" argument starting at i.
+func namedEquals(args []rune, i int) (int, bool) {
+	for i < len(args) && args[i] == ' ' {
+		i++
+	}
+	start := i
+	for i < len(args) && isIdentRune(args[i]) {
+		i++
+	}
+	if i == start {
+		return 0, false
+	}
+	for i < len(args) && args[i] == ' ' {
+		i++
+	}
+	if i+1 < len(args) && args[i] == '=' && args[i+1] != '=' {
+		return i, true
+	}
+	return 0, false
+}
+
+// colonize turns each top-level "name = value" argument into "name : value",
+// keeping every other rune in place.
+func colonize(args []rune) []rune {
+	out := append([]rune(nil), args...)
+	depth := 0
+	argStart := true
+	for i := 0; i < len(out); i++ {
+		if argStart && depth == 0 {
+			if eq, ok := namedEquals(out, i); ok {
+				out[eq] = ':'
+			}
+			argStart = false
+		}
+		switch {
+		case isQuote(out[i]):
+			i = skipString(out, i) - 1
+		case out[i] == '(' || out[i] == '[' || out[i] == '{':
+			depth++
+		case out[i] == ')' || out[i] == ']' || out[i] == '}':
+			depth--
+		case out[i] == ',' && depth == 0:
+			argStart = true
+		}
+	}
+	return out
+}
+
+func isQuote(r rune) bool {
+	return r == '"' || r == '\'' || r == '`'
+}
+
+// skipString returns the index just past the string literal starting at i.
+func skipString(rs []rune, i int) int {
+	q := rs[i]
+	for j := i + 1; j < len(rs); j++ {
+		switch {
+		case rs[j] == '\\' && q != '`':
+			j++
+		case rs[j] == q:
+			return j + 1
+		}
+	}
+	return len(rs)
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// unshiftError maps the column of a compile error in the namedArgs rewrite
+// back to the original expression.
+func unshiftError(err error, inserts []int) error {
+	var fe *file.Error
+	if len(inserts) == 0 || !errors.As(err, &fe) || fe.Line != 1 {
+		return err
+	}
+	for _, at := range inserts {
+		if at < fe.From {
+			fe.Column--
+		}
+	}
+	return err
+}
+
+// filterChecker validates the literal filters of accounts.* calls, so a
+// typo'd key or account type fails at load rather than on every evaluation.
+type filterChecker struct {
+	src string // the expression being compiled, for error positions
+	err error
+}
+
+func (c *filterChecker) Visit(node *ast.Node) {
+	call, ok := (*node).(*ast.CallNode)
+	if !ok || c.err != nil {
+		return
+	}
+	m, ok := call.Callee.(*ast.MemberNode)
+	if !ok {
+		return
+	}
+	if id, ok := m.Node.(*ast.IdentifierNode); !ok || id.Value != "accounts" {
+		return
+	}
+	for _, arg := range call.Arguments {
+		filters, ok := arg.(*ast.MapNode)
+		if !ok {
+			continue
+		}
+		for _, p := range filters.Pairs {
+			pair, ok := p.(*ast.PairNode)
+			if !ok {
+				continue
+			}
+			key, ok := pair.Key.(*ast.StringNode)
+			if !ok {
+				continue
+			}
+			c.checkFilter(key, pair.Value)
+		}
+	}
+}
+
+func (c *filterChecker) checkFilter(key *ast.StringNode, value ast.Node) {
+	str, isString := value.(*ast.StringNode)
+	switch key.Value {
+	case "type":
+		if !isString {
+			return
+		}
+		for _, t := range accountTypes {
+			if strings.EqualFold(t, str.Value) {
+				return
+			}
+		}
+		msg := fmt.Sprintf("unknown account type %q", str.Value)
+		if match, ok := closestName(str.Value, accountTypes); ok {
+			msg += fmt.Sprintf(" (did you mean %q?)", match)
+		}
+		c.fail(value, msg)
+	case "on_budget", "closed":
+		if isString {
+			c.fail(value, key.Value+" must be true or false")
+		}
+	case "match":
+		if isString {
+			if _, err := globPattern(str.Value); err != nil {
+				c.fail(value, fmt.Sprintf("match pattern %q is invalid", str.Value))
+			}
+		}
+	default:
+		msg := fmt.Sprintf("unknown accounts filter %q", key.Value)
+		if match, ok := closestName(key.Value, accountFilterKeys); ok {
+			msg += fmt.Sprintf(" (did you mean %q?)", match)
+		}
+		c.fail(key, msg)
+	}
+}
+
+func (c *filterChecker) fail(n ast.Node, msg string) {
+	fe := &file.Error{Location: n.Location(), Message: msg}
+	c.err = fe.Bind(file.NewSource(c.src))
+}
+
+var accountsMatchPattern = regexp.MustCompile(`accounts\.[a-z_]+\([^)]*\bmatch\s*[=:]\s*"([^"]*)"`)
+
+// accountPatterns returns the match patterns of the rule's accounts.* calls,
+// including through defs.
+func (r Rule) accountPatterns() []string {
+	var out []string
+	for _, e := range r.expressions() {
+		for _, m := range accountsMatchPattern.FindAllStringSubmatch(r.scope.expand(e), -1) {
+			out = append(out, m[1])
+		}
+	}
+	return out
+}
@@ -0,0 +1,141 @@
+package rules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNamedArgs(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{`accounts.sum(type="creditCard") > 0`, `accounts.sum({type:"creditCard"}) > 0`},
+		{`accounts.total(on_budget = true, match="Sav(1)*")`, `accounts.total({on_budget : true, match:"Sav(1)*"})`},
+		{`accounts.sum({type: "cash"})`, `accounts.sum({type: "cash"})`},
+		{`accounts.sum() == 0`, `accounts.sum() == 0`},
+		{`"accounts.sum(type=1)" == "x"`, `"accounts.sum(type=1)" == "x"`},
+		{`myaccounts.sum(type="x")`, `myaccounts.sum(type="x")`},
+	} {
+		if got, _ := namedArgs(tt.src); got != tt.want {
+			t.Errorf("namedArgs(%s) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"Savings*", "Savings/Trip", true},
+		{"*/Joint", "Checking/Joint", true},
+		{"Card ?", "Card /", true},
+		{"Visa*", "Amex", false},
+		{"[A-C]*", "Brokerage", true},
+		{"[!A-C]*", "Brokerage", false},
+		{`Cash \*`, "Cash *", true},
+		{`Cash \*`, "Cash box", false},
+		{"a.b", "axb", false},
+	} {
+		got, err := globMatch(tt.pattern, tt.name)
+		if err != nil || got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, %v; want %v", tt.pattern, tt.name, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"[a", "[z-a]", `a\`} {
+		if _, err := globMatch(bad, "a"); err == nil {
+			t.Errorf("expected %q to be an invalid pattern", bad)
+		}
+	}
+}
+
+func TestAccountsAggregates(t *testing.T) {
+	data := Data{
+		Accounts: map[string]int64{
+			"Checking":      1500000,
+			"Savings Main":  5000000,
+			"Savings Trip":  1000000,
+			"Visa":          -800000,
+			"Amex":          -300000,
+			"Brokerage":     9000000,
+			"Unknown Extra": 1000,
+			"Old Visa":      -200000,
+			"Gone":          7000000,
+		},
+		AccountInfo: map[string]AccountInfo{
+			"Checking":     {Type: "checking", OnBudget: true},
+			"Savings Main": {Type: "savings", OnBudget: true},
+			"Savings Trip": {Type: "savings", OnBudget: true},
+			"Visa":         {Type: "creditCard", OnBudget: true},
+			"Amex":         {Type: "creditCard", OnBudget: true},
+			"Brokerage":    {Type: "otherAsset", OnBudget: false},
+			"Old Visa":     {Type: "creditCard", OnBudget: true, Closed: true},
+			"Gone":         {Type: "checking", OnBudget: true, Deleted: true},
+		},
+		Now: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC),
+	}
+	for _, cond := range []string{
+		`accounts.sum(type="creditCard") == -1100`,
+		`-accounts.sum(type="creditCard") < account.balance("Checking")`,
+		`accounts.total(on_budget=true) == 6400`,
+		`accounts.sum({on_budget: false}) == 9001`,
+		`accounts.sum(match="Savings*") == 6000`,
+		`accounts.sum(type="savings", match="* Trip") == 1000`,
+		`accounts.min(type="creditCard") == -800`,
+		`accounts.max(on_budget=true) == 5000`,
+		`accounts.sum() == 15401`,
+		`accounts.sum(type="mortgage") == 0`,
+		`accounts.min(type="mortgage") == 0`,
+		`accounts.count(type="savings") == 2`,
+		`accounts.count(type="mortgage") == 0`,
+		`accounts.sum(type="creditCard", closed=true) == -200`,
+		`accounts.count(closed=false) == 7`,
+	} {
+		prog, err := compileCondition(cond, nil)
+		if err != nil {
+			t.Fatalf("compile %s: %v", cond, err)
+		}
+		ok, err := evaluateCondition(cond, prog, nil, data)
+		if err != nil || !ok {
+			t.Errorf("%s = %v, %v; want true", cond, ok, err)
+		}
+	}
+}
+
+func TestAccountsFilterErrors(t *testing.T) {
+	for _, tt := range []struct {
+		cond, pos, msg string
+	}{
+		{`accounts.sum(type="creditcrd") > 0`, "r.yaml:4:35", `unknown account type "creditcrd" (did you mean "creditCard"?)`},
+		{`accounts.sum(on_budgt=true) > 0`, "r.yaml:4:30", `unknown accounts filter "on_budgt" (did you mean "on_budget"?)`},
+		{`accounts.sum({match: "[a"}) > 0`, "r.yaml:4:38", `match pattern "[a" is invalid`},
+	} {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{"r.yaml": `
+- name: r
+  when:
+    condition: '` + tt.cond + `'
+`})
+		_, err := LoadDir(dir)
+		want := tt.pos + ": rule r: condition: " + tt.msg
+		if err == nil || err.Error() != want {
+			t.Errorf("expected %q, got %v", want, err)
+		}
+	}
+}
+
+func TestLintAccountPatterns(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"r.yaml": `
+- name: savings
+  when:
+    condition: accounts.sum(match="Savngs*") < 100
+`})
+	results, err := LintWithOptions(dir, time.Now(), LintOptions{PollInterval: time.Minute, Accounts: []string{"Savings Main"}})
+	if err != nil {
+		t.Fatalf("lint error: %v", err)
+	}
+	if !hasDiagnostic(results[0], `accounts match "Savngs*" matches no account`) {
+		t.Fatalf("expected match pattern warning, got %+v", results[0].Diagnostics)
+	}
+}
@@ -0,0 +1,138 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Periods for Observe.CaptureOncePer.
+const (
+	PerDay   = "day"
+	PerWeek  = "week"
+	PerMonth = "month"
+)
+
+// captureSchedule is a parsed Observe.CaptureOn: either every run, or the
+// same day gates a When clause uses.
+type captureSchedule struct {
+	always bool
+	gate   When
+}
+
+// parseCaptureOn accepts "always" (or empty), a day of month ("5", "-1"), a
+// day range ("27-5"), an nth weekday ("2 Tuesday", "last Friday"), weekdays
+// ("Mon", "Mon-Fri"), a business day ("last business day") or a cron
+// schedule ("0 9 1 * *").
+func parseCaptureOn(obs Observe) (captureSchedule, error) {
+	val := strings.TrimSpace(obs.CaptureOn)
+	if val == "" || strings.EqualFold(val, "always") {
+		return captureSchedule{always: true}, nil
+	}
+	if n, ok := parseBusinessDayCapture(val); ok {
+		return captureSchedule{gate: When{BusinessDayOfMonth: []int{n}}}, nil
+	}
+	if day, err := strconv.Atoi(val); err == nil {
+		if day == 0 || day < -31 || day > 31 {
+			return captureSchedule{}, fmt.Errorf("capture_on day %d is out of range -31..-1 or 1..31", day)
+		}
+		return captureSchedule{gate: When{DayOfMonth: []int{day}, Shift: obs.Shift}}, nil
+	}
+	if s, e, ok := parseRange(val); ok {
+		if s < 1 || s > 31 || e < 1 || e > 31 {
+			return captureSchedule{}, fmt.Errorf("capture_on range %q values must be within 1..31", val)
+		}
+		return captureSchedule{gate: When{DayOfMonthRanges: []string{val}}}, nil
+	}
+	if _, _, _, ok := parseNthWeekday(val); ok {
+		return captureSchedule{gate: When{NthWeekday: val}}, nil
+	}
+	if days, err := parseWeekdays(val); err == nil {
+		var names []string
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if days[d] {
+				names = append(names, d.String())
+			}
+		}
+		return captureSchedule{gate: When{DaysOfWeek: names}}, nil
+	}
+	if len(strings.Fields(val)) == 5 {
+		if _, err := cron.ParseStandard(val); err != nil {
+			return captureSchedule{}, fmt.Errorf("capture_on schedule invalid cron: %v", err)
+		}
+		return captureSchedule{gate: When{Schedule: val}}, nil
+	}
+	return captureSchedule{}, fmt.Errorf("capture_on value %q is invalid", obs.CaptureOn)
+}
+
+// matchesDay reports whether t falls on a capture day. Cron schedules are
+// handled by lastOccurrence instead.
+func (c captureSchedule) matchesDay(t time.Time, cal *Calendar) bool {
+	return c.always || (c.gate.Schedule == "" && dayGate(c.gate, t, cal) == "")
+}
+
+// lastOccurrence returns the most recent capture time at or before now: the
+// latest cron activation, or midnight of the latest capture day within a year.
+func (c captureSchedule) lastOccurrence(now time.Time, cal *Calendar) (time.Time, bool) {
+	if c.always {
+		return now, true
+	}
+	if c.gate.Schedule != "" {
+		sched, err := cron.ParseStandard(c.gate.Schedule)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return lastActivation(sched, now)
+	}
+	for i := 0; i <= 366; i++ {
+		day := now.AddDate(0, 0, -i)
+		if dayGate(c.gate, day, cal) == "" {
+			return startOfDay(day), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// samePeriod reports whether a and b fall in the same day, ISO week or month.
+func samePeriod(a, b time.Time, per string) bool {
+	switch per {
+	case PerWeek:
+		ay, aw := a.ISOWeek()
+		by, bw := b.ISOWeek()
+		return ay == by && aw == bw
+	case PerMonth:
+		return a.Year() == b.Year() && a.Month() == b.Month()
+	default:
+		return sameCalendarDay(a, b)
+	}
+}
+
+// captureDue decides whether obs should be captured at now given the last
+// recorded value, if any.
+func captureDue(obs Observe, sched captureSchedule, last ObservedValue, have bool, now time.Time, cal *Calendar) bool {
+	var due bool
+	switch {
+	case sched.always:
+		due = true
+	case sched.gate.Schedule != "":
+		// cron captures run on the first evaluation at or after each activation
+		occ, ok := sched.lastOccurrence(now, cal)
+		due = ok && (!have || last.RecordedAt.Before(occ))
+	case sched.matchesDay(now, cal):
+		due = !have || !sameCalendarDay(last.RecordedAt, now)
+	case obs.CatchUp:
+		// the daemon was not running (or not evaluating) on the last capture day
+		occ, ok := sched.lastOccurrence(now, cal)
+		due = ok && (!have || last.RecordedAt.Before(occ))
+		if due {
+			dbg.Debugf("catching up capture of %s missed on %s", obs.Variable, occ.Format("2006-01-02"))
+		}
+	}
+	if due && have && obs.CaptureOncePer != "" && samePeriod(last.RecordedAt, now, strings.ToLower(obs.CaptureOncePer)) {
+		due = false
+	}
+	return due
+}
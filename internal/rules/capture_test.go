@@ -0,0 +1,107 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseCaptureOnVocabulary(t *testing.T) {
+	// 2024-03-12 is the second Tuesday of March
+	tuesday := time.Date(2024, time.March, 12, 9, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		val    string
+		expect bool
+	}{
+		{"12", true},
+		{"-20", true}, // 31 - 20 + 1 = 12
+		{"10-14", true},
+		{"28-5", false},
+		{"2 Tuesday", true},
+		{"last Tuesday", false},
+		{"Tue", true},
+		{"Sat-Mon", false},
+		{"business day 8", true},
+	} {
+		sched, err := parseCaptureOn(Observe{CaptureOn: tt.val})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.val, err)
+		}
+		if got := sched.matchesDay(tuesday, nil); got != tt.expect {
+			t.Fatalf("%s: expected %v got %v", tt.val, tt.expect, got)
+		}
+	}
+	for _, bad := range []string{"0", "someday", "0 9 * *", "61 9 * * *"} {
+		if _, err := parseCaptureOn(Observe{CaptureOn: bad}); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestCaptureCatchUpAndOncePer(t *testing.T) {
+	store, err := NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	balance := int64(10_000)
+	run := func(obs Observe, now time.Time) {
+		t.Helper()
+		r := Rule{Name: "r", Observe: ObserveList{obs}}
+		data := Data{Accounts: map[string]int64{"Card": balance}, Vars: map[string]int64{}, Now: now}
+		if _, err := Evaluate(context.Background(), []Rule{r}, store, data); err != nil {
+			t.Fatalf("evaluate error: %v", err)
+		}
+	}
+	value := func(name string) int64 {
+		v, _ := store.Get(name)
+		return v.Value
+	}
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 9, 0, 0, 0, time.UTC) }
+
+	// catch-up: the 5th was missed, so capture on the next run
+	stmt := Observe{CaptureOn: "5", CatchUp: true, Variable: "stmt", Value: `account.balance("Card")`}
+	run(stmt, day(time.March, 7))
+	if value("stmt") != 10_000 {
+		t.Fatalf("expected catch-up capture, got %d", value("stmt"))
+	}
+	balance = 20_000
+	run(stmt, day(time.March, 9))
+	if value("stmt") != 10_000 {
+		t.Fatalf("expected no second catch-up in the same cycle, got %d", value("stmt"))
+	}
+	run(stmt, day(time.April, 5))
+	if value("stmt") != 20_000 {
+		t.Fatalf("expected capture on the next 5th, got %d", value("stmt"))
+	}
+
+	// without catch_up a missed day waits for the next occurrence
+	plain := Observe{CaptureOn: "5", Variable: "plain", Value: `account.balance("Card")`}
+	run(plain, day(time.March, 7))
+	if _, ok := store.Get("plain"); ok {
+		t.Fatalf("expected no capture without catch_up")
+	}
+
+	// once per month over a day range
+	ranged := Observe{CaptureOn: "10-20", CaptureOncePer: "month", Variable: "ranged", Value: `account.balance("Card")`}
+	balance = 30_000
+	run(ranged, day(time.March, 10))
+	balance = 40_000
+	run(ranged, day(time.March, 11))
+	if value("ranged") != 30_000 {
+		t.Fatalf("expected one capture per month, got %d", value("ranged"))
+	}
+	run(ranged, day(time.April, 12))
+	if value("ranged") != 40_000 {
+		t.Fatalf("expected capture in the next month, got %d", value("ranged"))
+	}
+
+	// cron captures run on the first evaluation after each activation
+	cronObs := Observe{CaptureOn: "0 8 * * 1", Variable: "weekly", Value: `account.balance("Card")`}
+	balance = 50_000
+	run(cronObs, time.Date(2024, time.March, 11, 8, 40, 0, 0, time.UTC)) // Monday, after 08:00
+	balance = 60_000
+	run(cronObs, time.Date(2024, time.March, 12, 8, 40, 0, 0, time.UTC))
+	if value("weekly") != 50_000 {
+		t.Fatalf("expected a single capture per activation, got %d", value("weekly"))
+	}
+}
@@ -0,0 +1,267 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/file"
+	"github.com/expr-lang/expr/vm"
+	"gopkg.in/yaml.v3"
+)
+
+// Position is a location in a rule file. Line and Column are 1-based; a zero
+// Line means the position is unknown (e.g. rules built in code).
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.Line == 0 {
+		return p.File
+	}
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// ExprError is an expression that does not compile against the evaluation
+// environment or does not produce the expected type.
+type ExprError struct {
+	Rule  string
+	Field string // condition, clear_condition or value
+	Pos   Position
+	Err   error
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("%srule %s: %s: %s", posPrefix(e.Pos), e.Rule, e.Field, exprMessage(e.Err))
+}
+
+func (e *ExprError) Unwrap() error {
+	return e.Err
+}
+
+func exprMessage(err error) string {
+	var fe *file.Error
+	if errors.As(err, &fe) {
+		return fe.Message
+	}
+	return err.Error()
+}
+
+// ProgramCache keeps compiled expressions between loads of a rule
+// directory, so reloading unchanged rules every tick does not recompile
+// them. Each load keeps only the programs its rules use, so edited or
+// removed expressions do not pile up.
+type ProgramCache struct {
+	mu   sync.Mutex
+	m    map[string]*vm.Program
+	used map[string]bool // keys compiled or reused by the current load
+}
+
+// NewProgramCache returns an empty cache for LoadDirWithCache.
+func NewProgramCache() *ProgramCache {
+	return &ProgramCache{m: map[string]*vm.Program{}, used: map[string]bool{}}
+}
+
+// compile returns the program for src, compiling it on a miss. A nil cache
+// always compiles.
+func (c *ProgramCache) compile(kind, src string, sc *scope, opts ...expr.Option) (*vm.Program, error) {
+	if c == nil {
+		return compileExpr(src, sc, nil, opts...)
+	}
+	key := kind + "\x00" + src + "\x00" + sc.cacheKey()
+	c.mu.Lock()
+	prog, ok := c.m[key]
+	c.used[key] = true
+	c.mu.Unlock()
+	if ok {
+		return prog, nil
+	}
+	prog, err := compileExpr(src, sc, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.m[key] = prog
+	c.mu.Unlock()
+	return prog, nil
+}
+
+// endLoad finishes a load, dropping the programs it did not use when it
+// succeeded. A failed load stops early, so it keeps everything.
+func (c *ProgramCache) endLoad(ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ok {
+		for key := range c.m {
+			if !c.used[key] {
+				delete(c.m, key)
+			}
+		}
+	}
+	c.used = map[string]bool{}
+}
+
+func (c *ProgramCache) condition(src string, sc *scope) (*vm.Program, error) {
+	return c.compile("bool", src, sc, expr.AsBool())
+}
+
+func (c *ProgramCache) amount(src string, sc *scope) (*vm.Program, error) {
+	return c.compile("number", src, sc, expr.AsFloat64())
+}
+
+// compileExpr compiles src against the evaluation environment type. It
+// resolves const and def references in sc (stack lists the defs being
+// compiled, to detect cycles) and named arguments of accounts.* calls.
+func compileExpr(src string, sc *scope, stack []string, opts ...expr.Option) (*vm.Program, error) {
+	rewritten, inserts := namedArgs(src)
+	patcher := &scopePatcher{scope: sc, src: rewritten, stack: stack}
+	filters := &filterChecker{src: rewritten}
+	prog, err := expr.Compile(rewritten, append([]expr.Option{expr.Env(evalEnv{}), expr.Patch(patcher), expr.Patch(filters)}, opts...)...)
+	switch {
+	case patcher.err != nil:
+		err = patcher.err
+	case filters.err != nil:
+		err = filters.err
+	}
+	if err != nil {
+		return nil, unshiftError(err, inserts)
+	}
+	return prog, nil
+}
+
+// compileCondition compiles a boolean expression against the evaluation
+// environment type, resolving const and def references in sc.
+func compileCondition(src string, sc *scope) (*vm.Program, error) {
+	return (*ProgramCache)(nil).condition(src, sc)
+}
+
+// compileAmount compiles a numeric expression against the evaluation
+// environment type, resolving const and def references in sc.
+func compileAmount(src string, sc *scope) (*vm.Program, error) {
+	return (*ProgramCache)(nil).amount(src, sc)
+}
+
+// Compile compiles every expression of rules in place so evaluation reuses
+// the programs, and resolves each rule's timezone. It returns one ExprError
+// per expression that fails and an error per invalid timezone.
+func Compile(rules []Rule) []error {
+	return compileRules(rules, nil)
+}
+
+func compileRules(rules []Rule, cache *ProgramCache) []error {
+	var errs []error
+	for i := range rules {
+		if err := rules[i].resolveTimezone(); err != nil {
+			errs = append(errs, fmt.Errorf("%srule %s: %w", posPrefix(rules[i].Pos), rules[i].Name, err))
+		}
+		errs = append(errs, rules[i].compile(cache)...)
+	}
+	return errs
+}
+
+func (r *Rule) compile(cache *ProgramCache) []error {
+	var errs []error
+	fail := func(field string, pos Position, src string, err error) {
+		errs = append(errs, &ExprError{Rule: r.Name, Field: field, Pos: errorPosition(pos, src, err), Err: err})
+	}
+	for i := range r.Observe {
+		obs := &r.Observe[i]
+		if strings.TrimSpace(obs.Value) == "" {
+			continue
+		}
+		prog, err := cache.amount(obs.Value, r.scope)
+		if err != nil {
+			fail("value", obs.valuePos, obs.Value, err)
+			continue
+		}
+		obs.value = prog
+	}
+	for i := range r.When {
+		w := &r.When[i]
+		if strings.TrimSpace(w.Condition) != "" {
+			prog, err := cache.condition(w.Condition, r.scope)
+			if err != nil {
+				fail("condition", w.conditionPos, w.Condition, err)
+			} else {
+				w.condition = prog
+			}
+		}
+		if strings.TrimSpace(w.ClearCondition) != "" {
+			prog, err := cache.condition(w.ClearCondition, r.scope)
+			if err != nil {
+				fail("clear_condition", w.clearPos, w.ClearCondition, err)
+			} else {
+				w.clear = prog
+			}
+		}
+	}
+	return errs
+}
+
+// setFile records the rule file on every position in the rule.
+func (r *Rule) setFile(name string) {
+	r.Pos.File = name
+	setKeysFile(r.unknown, name)
+	for i := range r.Observe {
+		r.Observe[i].Pos.File = name
+		r.Observe[i].valuePos.File = name
+		setKeysFile(r.Observe[i].unknown, name)
+	}
+	for i := range r.When {
+		r.When[i].Pos.File = name
+		r.When[i].conditionPos.File = name
+		r.When[i].clearPos.File = name
+		setKeysFile(r.When[i].unknown, name)
+	}
+}
+
+func setKeysFile(keys []unknownKey, name string) {
+	for i := range keys {
+		keys[i].Pos.File = name
+	}
+}
+
+// errorPosition moves pos, the start of an expression in its rule file, to
+// the offending token when the expression fits on one line.
+func errorPosition(pos Position, src string, err error) Position {
+	var fe *file.Error
+	if pos.Line == 0 || !errors.As(err, &fe) || fe.Line != 1 || strings.Contains(src, "\n") {
+		return pos
+	}
+	pos.Column += fe.Column
+	return pos
+}
+
+// scalarPosition returns where the text of a scalar node starts, skipping
+// the opening quote of quoted scalars. Block scalars report the node itself.
+func scalarPosition(n *yaml.Node) Position {
+	pos := Position{Line: n.Line, Column: n.Column}
+	switch n.Style {
+	case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+		pos.Column++
+	}
+	return pos
+}
+
+// valuePositions maps the keys of a mapping node to the positions of their
+// scalar values.
+func valuePositions(n *yaml.Node) map[string]Position {
+	out := map[string]Position{}
+	if n.Kind != yaml.MappingNode {
+		return out
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if v := n.Content[i+1]; v.Kind == yaml.ScalarNode {
+			out[n.Content[i].Value] = scalarPosition(v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,228 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadDirCompilesExpressions(t *testing.T) {
+	dir := t.TempDir()
+	content := `
+- name: ok
+  observe:
+    variable: start
+    value: 50
+  when:
+    condition: account.balance("Checking") < var.start
+`
+	if err := os.WriteFile(filepath.Join(dir, "ok.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	rs, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if rs[0].When[0].condition == nil || rs[0].Observe[0].value == nil {
+		t.Fatalf("expected expressions to be compiled at load")
+	}
+}
+
+func TestLoadDirWithCacheReusesAndPrunes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(cond string) {
+		t.Helper()
+		writeFiles(t, dir, map[string]string{"r.yaml": "- name: r\n  when:\n    condition: " + cond + "\n"})
+	}
+	cache := NewProgramCache()
+	write(`account.balance("Checking") < 100`)
+	first, err := LoadDirWithCache(dir, cache)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	again, err := LoadDirWithCache(dir, cache)
+	if err != nil || again[0].When[0].condition != first[0].When[0].condition {
+		t.Fatalf("expected an unchanged rule to reuse its program (err %v)", err)
+	}
+
+	write(`account.balance("Checking") < 200`)
+	if _, err := LoadDirWithCache(dir, cache); err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if len(cache.m) != 1 {
+		t.Fatalf("expected the edited expression's old program to be pruned, have %d", len(cache.m))
+	}
+	write(`account.balance("Checking") <`)
+	if _, err := LoadDirWithCache(dir, cache); err == nil || len(cache.m) != 1 {
+		t.Fatalf("expected a failed load to keep the cache, have %d (err %v)", len(cache.m), err)
+	}
+}
+
+func TestLoadDirWithCacheKeepsScopeLevelsApart(t *testing.T) {
+	dir := t.TempDir()
+	write := func(file, global int) {
+		t.Helper()
+		writeFiles(t, dir, map[string]string{"r.yaml": fmt.Sprintf(`
+global:
+  constants:
+    limit: %d
+constants:
+  limit: %d
+rules:
+  - name: r
+    when:
+      condition: account.balance("Checking") < const.limit
+`, global, file)})
+	}
+	cache := NewProgramCache()
+	data := Data{Accounts: map[string]int64{"Checking": 150_000}, Now: time.Now()}
+	write(100, 200) // the file's 100 shadows the global 200
+	rs, err := LoadDirWithCache(dir, cache)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if trigs, err := Evaluate(context.Background(), rs, nil, data); err != nil || len(trigs) != 0 {
+		t.Fatalf("expected 150 < 100 not to fire, got %+v (%v)", trigs, err)
+	}
+	write(200, 100) // same names, levels swapped
+	if rs, err = LoadDirWithCache(dir, cache); err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if trigs, err := Evaluate(context.Background(), rs, nil, data); err != nil || len(trigs) != 1 {
+		t.Fatalf("expected 150 < 200 to fire with a fresh program, got %+v (%v)", trigs, err)
+	}
+}
+
+func TestLoadDirReportsExpressionPosition(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		content string
+		pos     string
+		field   string
+	}{
+		{
+			name: "not boolean",
+			content: `
+- name: amount_condition
+  when:
+    condition: account.balance("Checking") + 1
+`,
+			pos:   "r.yaml:4:16",
+			field: "condition",
+		},
+		{
+			name: "unknown function",
+			content: `
+- name: typo
+  when:
+    condition: "account.balanse(\"Checking\") < 1"
+`,
+			pos:   "r.yaml:4:25",
+			field: "condition",
+		},
+		{
+			name: "value not a number",
+			content: `
+- name: bool_value
+  observe:
+    variable: v
+    value: account.balance("Checking") > 1
+  when:
+    condition: var.v > 1
+`,
+			pos:   "r.yaml:5:12",
+			field: "value",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "r.yaml"), []byte(tt.content), 0o644); err != nil {
+				t.Fatalf("write error: %v", err)
+			}
+			_, err := LoadDir(dir)
+			var ee *ExprError
+			if !errors.As(err, &ee) {
+				t.Fatalf("expected ExprError, got %v", err)
+			}
+			if ee.Field != tt.field || !strings.HasPrefix(err.Error(), tt.pos+":") {
+				t.Fatalf("expected %s at %s, got %v", tt.field, tt.pos, err)
+			}
+		})
+	}
+}
+
+func TestLintReportsCompileErrors(t *testing.T) {
+	dir := t.TempDir()
+	content := `
+- name: broken
+  when:
+    condition: account.balance("Checking") <
+`
+	if err := os.WriteFile(filepath.Join(dir, "r.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	results, err := LintWithPoll(dir, time.Now(), time.Minute)
+	if err != nil {
+		t.Fatalf("lint should report compile errors as issues, got %v", err)
+	}
+	if len(results) != 1 || len(results[0].Diagnostics) == 0 {
+		t.Fatalf("expected compile issue, got %+v", results)
+	}
+	d := results[0].Diagnostics[0]
+	if d.Level != LintError || !strings.HasPrefix(d.Message, "condition does not compile") || d.Pos.File != "r.yaml" || d.Pos.Line != 4 {
+		t.Fatalf("expected compile error at r.yaml:4, got %+v", d)
+	}
+}
+
+func TestLoadDirRecordsPositions(t *testing.T) {
+	dir := t.TempDir()
+	content := `- name: low
+  observe:
+    variable: start
+    value: account.balance("Checking")
+  when:
+    - condition: account.balance("Checking") < 10
+    - condition: account.balance("Missing") < 10
+- name: bad_type
+  when: [1, 2]
+`
+	if err := os.WriteFile(filepath.Join(dir, "r.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	_, err := LoadDir(dir)
+	if err == nil || !strings.HasPrefix(err.Error(), "parsing r.yaml:8:3:") {
+		t.Fatalf("expected decode error at the failing rule, got %v", err)
+	}
+	if d := LoadFailure(err).Diagnostics[0]; d.Level != LintError || d.Pos.String() != "r.yaml:8:3" {
+		t.Fatalf("expected load failure at r.yaml:8:3, got %+v", d)
+	}
+
+	content = content[:strings.Index(content, "- name: bad_type")]
+	if err := os.WriteFile(filepath.Join(dir, "r.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	rs, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	r := rs[0]
+	if r.Pos.String() != "r.yaml:1:3" || r.Observe[0].Pos.String() != "r.yaml:3:5" || r.When[1].Pos.String() != "r.yaml:7:7" {
+		t.Fatalf("unexpected positions: rule %s observe %s when %s", r.Pos, r.Observe[0].Pos, r.When[1].Pos)
+	}
+
+	data := Data{Accounts: map[string]int64{"Checking": 5_000}, Vars: map[string]int64{}, Now: time.Now()}
+	_, err = Evaluate(context.Background(), rs, nil, data)
+	if err == nil || !strings.HasPrefix(err.Error(), "r.yaml:7:7: rule low:") {
+		t.Fatalf("expected runtime error with clause position, got %v", err)
+	}
+	data.Accounts["Missing"] = 50_000
+	trigs, err := Evaluate(context.Background(), rs, nil, data)
+	if err != nil || len(trigs) != 1 || trigs[0].Pos().String() != "r.yaml:6:7" {
+		t.Fatalf("expected trigger from r.yaml:6:7, got %+v (%v)", trigs, err)
+	}
+}
@@ -0,0 +1,91 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+)
+
+// dateFuncs are the date and time helpers exposed to expressions. They are
+// relative to Data.Now rather than the wall clock so evaluation stays
+// deterministic.
+type dateFuncs struct {
+	Now              func() time.Time               `expr:"now"`
+	Today            func() time.Time               `expr:"today"`
+	DayOfMonth       func() int                     `expr:"day_of_month"`
+	DaysInMonth      func() int                     `expr:"days_in_month"`
+	DaysLeftInMonth  func() int                     `expr:"days_left_in_month"` // including today
+	DaysUntil        func(int) (int, error)         `expr:"days_until"`         // next occurrence of a day of month
+	Weekday          func() string                  `expr:"weekday"`            // e.g. "Monday"
+	AddDays          func(time.Time, int) time.Time `expr:"add_days"`
+	DaysBetween      func(time.Time, time.Time) int `expr:"days_between"` // calendar days from a to b
+	IsBusinessDay    func() bool                    `expr:"is_business_day"`
+	BusinessDaysLeft func() int                     `expr:"business_days_left_in_month"` // including today
+}
+
+func buildDateFuncs(now time.Time, cal *Calendar) dateFuncs {
+	today := startOfDay(now)
+	return dateFuncs{
+		Now:             func() time.Time { return now },
+		Today:           func() time.Time { return today },
+		DayOfMonth:      func() int { return now.Day() },
+		DaysInMonth:     func() int { return daysInMonth(now) },
+		DaysLeftInMonth: func() int { return daysInMonth(now) - now.Day() + 1 },
+		DaysUntil:       func(day int) (int, error) { return daysUntil(today, day) },
+		Weekday:         func() string { return now.Weekday().String() },
+		AddDays:         func(t time.Time, n int) time.Time { return t.AddDate(0, 0, n) },
+		DaysBetween:     calendarDaysBetween,
+		IsBusinessDay:   func() bool { return cal.IsBusinessDay(now) },
+		BusinessDaysLeft: func() int {
+			n := 0
+			for d := today; d.Month() == today.Month(); d = d.AddDate(0, 0, 1) {
+				if cal.IsBusinessDay(d) {
+					n++
+				}
+			}
+			return n
+		},
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// daysUntil counts days from today to the next date whose day of month is
+// day (today counts as 0). Negative days count from the end of the month
+// (-1 = last day); days past a short month's end clamp to its last day.
+func daysUntil(today time.Time, day int) (int, error) {
+	if day == 0 || day < -31 || day > 31 {
+		return 0, fmt.Errorf("days_until: day %d is out of range -31..-1 or 1..31", day)
+	}
+	target := dayInMonth(today, day)
+	if target.Before(today) {
+		next := time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, today.Location())
+		target = dayInMonth(next, day)
+	}
+	return calendarDaysBetween(today, target), nil
+}
+
+// dayInMonth resolves day within month's month, clamped to its length.
+func dayInMonth(month time.Time, day int) time.Time {
+	last := daysInMonth(month)
+	d := day
+	if d < 0 {
+		d = last + d + 1
+		if d < 1 {
+			d = 1
+		}
+	}
+	if d > last {
+		d = last
+	}
+	return time.Date(month.Year(), month.Month(), d, 0, 0, 0, 0, month.Location())
+}
+
+// calendarDaysBetween counts calendar days from a to b, ignoring the time of
+// day and DST shifts.
+func calendarDaysBetween(a, b time.Time) int {
+	ad := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	bd := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(bd.Sub(ad).Hours() / 24)
+}
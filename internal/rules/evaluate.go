@@ -2,6 +2,7 @@ package rules
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"math"
@@ -10,26 +11,36 @@ import (
 	"time"
 
 	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
 	"github.com/robfig/cron/v3"
 )
 
 // Evaluate applies all rules against the provided data, capturing observations as needed.
 func Evaluate(ctx context.Context, rules []Rule, store *Store, data Data) ([]Trigger, error) {
-	var triggers []Trigger
+	res, err := EvaluateRules(ctx, rules, store, data)
+	return res.Triggers, err
+}
+
+// EvaluateRules is like Evaluate but also reports which rules had a condition
+// checked, so callers can tell "not firing" apart from "not evaluated".
+func EvaluateRules(ctx context.Context, rules []Rule, store *Store, data Data) (Result, error) {
+	var res Result
 
+	base := data.Now
 	for _, rule := range rules {
 		select {
 		case <-ctx.Done():
-			return triggers, ctx.Err()
+			return res, ctx.Err()
 		default:
 		}
+		data.Now = rule.In(base)
 
 		for _, obs := range rule.Observe {
 			if store == nil {
 				break
 			}
-			if err := captureObservation(obs, store, data); err != nil {
-				return triggers, fmt.Errorf("capture %s: %w", rule.Name, err)
+			if err := captureObservation(obs, rule.scope, store, data); err != nil {
+				return res, fmt.Errorf("%scapture %s: %w", posPrefix(obs.Pos), rule.Name, err)
 			}
 			// refresh vars after capture
 			data.Vars = store.Snapshot()
@@ -38,52 +49,155 @@ func Evaluate(ctx context.Context, rules []Rule, store *Store, data Data) ([]Tri
 		if len(rule.When) == 0 {
 			continue
 		}
+		if store != nil {
+			if sil, ok := store.Silenced(rule.Name, data.Now); ok {
+				dbg.Debugf("rule %s silenced (until %s): %s", rule.Name, silenceUntil(sil), sil.Reason)
+				res.Silenced = append(res.Silenced, rule.Name)
+				continue
+			}
+		}
 
+		checked := false
 		for _, when := range rule.When {
 			if when.Condition == "" {
 				continue
 			}
-			if !shouldEvaluate(when, data.Now, rule.Name) {
+			key := clauseKey(rule.Name, when)
+			var activation time.Time
+			if when.Schedule != "" && store != nil {
+				due, occ, err := scheduleDue(store, key, when, data.Now)
+				if err != nil {
+					return res, ruleError(rule.Name, when.Pos, err)
+				}
+				if !due {
+					continue
+				}
+				activation = occ
+			} else if !shouldEvaluate(when, data.Now, rule.Name, data.Calendar) {
 				continue
 			}
-			ok, err := evaluateCondition(when.Condition, data)
+			checked = true
+			ok, err := evaluateCondition(when.Condition, when.condition, rule.scope, data)
 			if err != nil {
-				return triggers, fmt.Errorf("rule %s: %w", rule.Name, err)
+				return res, ruleError(rule.Name, when.Pos, err)
+			}
+			if store != nil && when.stateful() {
+				ok, err = holdClause(store, key, when, rule.scope, ok, data)
+				if err != nil {
+					return res, ruleError(rule.Name, when.Pos, err)
+				}
+			}
+			if !activation.IsZero() {
+				// only now is the activation handled; a failed evaluation retries it
+				if err := markScheduled(store, key, activation); err != nil {
+					return res, ruleError(rule.Name, when.Pos, err)
+				}
 			}
 			if ok {
 				dbg.Debugf("rule %s condition matched: %s", rule.Name, when.Condition)
-				triggers = append(triggers, Trigger{
-					Rule:    rule,
-					Message: fmt.Sprintf("Rule %s triggered: %s", rule.Name, when.Condition),
+				res.Triggers = append(res.Triggers, Trigger{
+					Rule:     rule,
+					When:     when,
+					Message:  fmt.Sprintf("Rule %s triggered: %s", rule.Name, when.Condition),
+					Severity: rule.Level(),
 				})
 			}
 		}
+		if checked {
+			res.Checked = append(res.Checked, rule.Name)
+		}
 	}
-	return triggers, nil
+	return res, nil
 }
 
-func captureObservation(obs Observe, store *Store, data Data) error {
-	if obs.Variable == "" || obs.Value == "" {
-		return errors.New("observation missing variable or value")
+// ruleError prefixes err with the rule name and, when known, where the
+// failing clause is defined.
+func ruleError(name string, pos Position, err error) error {
+	return fmt.Errorf("%srule %s: %w", posPrefix(pos), name, err)
+}
+
+// posPrefix returns "file:line:col: " for known positions and "" otherwise.
+func posPrefix(pos Position) string {
+	if pos.Line == 0 {
+		return ""
 	}
+	return pos.String() + ": "
+}
 
-	shouldCapture := false
-	now := data.Now
-	if obs.CaptureOn == "" || strings.EqualFold(obs.CaptureOn, "always") {
-		shouldCapture = true
-	} else if day, err := strconv.Atoi(obs.CaptureOn); err == nil {
-		if now.Day() == day {
-			if existing, ok := store.Get(obs.Variable); !ok || !sameCalendarDay(existing.RecordedAt, now) {
-				shouldCapture = true
+// clauseKey identifies a when clause's stored state by what it checks
+// rather than its position, so reordering or inserting clauses does not hand
+// one clause's state to another. Tuning for or clear_condition keeps it.
+func clauseKey(rule string, when When) string {
+	h := sha256.New()
+	for _, part := range []interface{}{
+		when.Condition, when.Schedule, when.Window, when.DayOfMonth, when.DayOfMonthRanges,
+		when.DaysOfWeek, when.NthWeekday, when.BusinessDayOfMonth, when.Shift,
+	} {
+		fmt.Fprintf(h, "%v\x00", part)
+	}
+	return fmt.Sprintf("%s#%x", rule, h.Sum(nil)[:6])
+}
+
+// holdClause applies a clause's for/clear_condition hysteresis to its raw
+// result. The condition must match on consecutive evaluations spanning For
+// before the clause fires; once firing it stays firing until the condition
+// stops matching and ClearCondition (when set) is true.
+func holdClause(store *Store, key string, when When, sc *scope, matched bool, data Data) (bool, error) {
+	st := store.Clause(key)
+	if matched {
+		if st.PendingSince.IsZero() {
+			st.PendingSince = data.Now
+		}
+		if held := data.Now.Sub(st.PendingSince); held >= time.Duration(when.For) {
+			st.Firing = true
+		} else {
+			dbg.Debugf("clause %s pending for %s of %s", key, held, time.Duration(when.For))
+		}
+	} else {
+		st.PendingSince = time.Time{}
+		if st.Firing {
+			cleared := true
+			if strings.TrimSpace(when.ClearCondition) != "" {
+				var err error
+				cleared, err = evaluateCondition(when.ClearCondition, when.clear, sc, data)
+				if err != nil {
+					return false, fmt.Errorf("clear_condition: %w", err)
+				}
+			}
+			if cleared {
+				st.Firing = false
+			} else {
+				dbg.Debugf("clause %s still firing until clear_condition: %s", key, when.ClearCondition)
 			}
 		}
 	}
+	return st.Firing, store.SetClause(key, st)
+}
 
-	if !shouldCapture {
+func silenceUntil(sil Silence) string {
+	if sil.Muted() {
+		return "unmuted"
+	}
+	return sil.Until.Format(time.RFC3339)
+}
+
+func captureObservation(obs Observe, sc *scope, store *Store, data Data) error {
+	if obs.Variable == "" || obs.Value == "" {
+		return errors.New("observation missing variable or value")
+	}
+
+	now := data.Now
+	sched, err := parseCaptureOn(obs)
+	if err != nil {
+		dbg.Debugf("skipping capture of %s: %v", obs.Variable, err)
+		return nil
+	}
+	existing, have := store.Get(obs.Variable)
+	if !captureDue(obs, sched, existing, have, now, data.Calendar) {
 		return nil
 	}
 
-	val, err := evalAmount(obs.Value, data)
+	val, err := evalAmount(obs.Value, obs.value, sc, data)
 	if err != nil {
 		return err
 	}
@@ -97,17 +211,20 @@ func captureObservation(obs Observe, store *Store, data Data) error {
 	return nil
 }
 
-func evaluateCondition(cond string, data Data) (bool, error) {
-	if missing := missingVars(cond, data.Vars); len(missing) > 0 {
+// evaluateCondition runs cond, reusing program when it was compiled at load.
+// Vars referenced through defs in sc count as referenced by cond.
+func evaluateCondition(cond string, program *vm.Program, sc *scope, data Data) (bool, error) {
+	if missing := missingVars(sc.expand(cond), data.Vars); len(missing) > 0 {
 		dbg.Debugf("skipping condition %q: missing vars: %v", cond, missing)
 		return false, nil
 	}
-	env := buildEnv(data)
-	program, err := expr.Compile(cond, expr.Env(env))
-	if err != nil {
-		return false, err
+	if program == nil {
+		var err error
+		if program, err = compileCondition(cond, sc); err != nil {
+			return false, err
+		}
 	}
-	out, err := expr.Run(program, env)
+	out, err := expr.Run(program, buildEnv(data))
 	if err != nil {
 		return false, err
 	}
@@ -118,16 +235,18 @@ func evaluateCondition(cond string, data Data) (bool, error) {
 	return b, nil
 }
 
-func evalAmount(exprStr string, data Data) (int64, error) {
-	if missing := missingVars(exprStr, data.Vars); len(missing) > 0 {
+// evalAmount runs exprStr, reusing program when it was compiled at load.
+func evalAmount(exprStr string, program *vm.Program, sc *scope, data Data) (int64, error) {
+	if missing := missingVars(sc.expand(exprStr), data.Vars); len(missing) > 0 {
 		return 0, fmt.Errorf("variable %q not found", missing[0])
 	}
-	env := buildEnv(data)
-	program, err := expr.Compile(exprStr, expr.Env(env))
-	if err != nil {
-		return 0, err
+	if program == nil {
+		var err error
+		if program, err = compileAmount(exprStr, sc); err != nil {
+			return 0, err
+		}
 	}
-	out, err := expr.Run(program, env)
+	out, err := expr.Run(program, buildEnv(data))
 	if err != nil {
 		return 0, err
 	}
@@ -139,8 +258,10 @@ func evalAmount(exprStr string, data Data) (int64, error) {
 }
 
 type evalEnv struct {
-	Account accountFuncs       `expr:"account"`
-	Var     map[string]float64 `expr:"var"`
+	dateFuncs
+	Account  accountFuncs       `expr:"account"`
+	Accounts accountsFuncs      `expr:"accounts"`
+	Var      map[string]float64 `expr:"var"`
 }
 
 type accountFuncs struct {
@@ -165,8 +286,10 @@ func buildEnv(data Data) evalEnv {
 		Due:     valueForAccount,
 	}
 	return evalEnv{
-		Account: account,
-		Var:     vars,
+		dateFuncs: buildDateFuncs(data.Now, data.Calendar),
+		Account:   account,
+		Accounts:  buildAccountsFuncs(data),
+		Var:       vars,
 	}
 }
 
@@ -217,7 +340,9 @@ func toMilli(val float64) int64 {
 	return int64(math.Round(val * 1000))
 }
 
+// sameCalendarDay compares calendar days in b's time zone.
 func sameCalendarDay(a, b time.Time) bool {
+	a = a.In(b.Location())
 	return a.Year() == b.Year() && a.Month() == b.Month() && a.Day() == b.Day()
 }
 
@@ -234,7 +359,7 @@ func parseRange(r string) (start int, end int, ok bool) {
 	return s, e, true
 }
 
-func shouldEvaluate(when When, now time.Time, ruleName string) bool {
+func shouldEvaluate(when When, now time.Time, ruleName string, cal *Calendar) bool {
 	// schedule (cron) wins if set
 	if when.Schedule != "" {
 		sched, err := cron.ParseStandard(when.Schedule)
@@ -242,30 +367,46 @@ func shouldEvaluate(when When, now time.Time, ruleName string) bool {
 			dbg.Debugf("rule %s skipping invalid schedule %q: %v", ruleName, when.Schedule, err)
 			return false
 		}
-		// check if now matches the schedule tick
+		// without a store to remember runs, match only the activation minute
 		prev := sched.Next(now.Add(-time.Minute * 2))
 		return sameMinute(prev, now)
 	}
 
-	if len(when.DayOfMonth) > 0 && !matchesDayOfMonth(when.DayOfMonth, now.Day(), daysInMonth(now)) {
-		dbg.Debugf("rule %s day_of_month gate does not match at %s", ruleName, now.Format(time.RFC3339))
+	if gate := dayGate(when, now, cal); gate != "" {
+		dbg.Debugf("rule %s %s gate does not match at %s", ruleName, gate, now.Format(time.RFC3339))
 		return false
 	}
-	if len(when.DayOfMonthRanges) > 0 && !matchesDayOfMonthRange(when.DayOfMonthRanges, now.Day(), daysInMonth(now)) {
-		dbg.Debugf("rule %s day_of_month_range gate does not match at %s", ruleName, now.Format(time.RFC3339))
-		return false
-	}
-	if len(when.DaysOfWeek) > 0 && !matchesDayOfWeek(when.DaysOfWeek, now.Weekday()) {
-		dbg.Debugf("rule %s days_of_week gate does not match at %s", ruleName, now.Format(time.RFC3339))
-		return false
-	}
-	if when.NthWeekday != "" && !matchesNthWeekday(when.NthWeekday, now) {
-		dbg.Debugf("rule %s nth_weekday gate %q does not match at %s", ruleName, when.NthWeekday, now.Format(time.RFC3339))
+	if !withinWindow(when, now) {
+		dbg.Debugf("rule %s window %q does not match at %s", ruleName, when.Window, now.Format(time.RFC3339))
 		return false
 	}
 	return true
 }
 
+// dayGate returns the name of the first calendar-day gate on when that does
+// not match t, or "" when they all match.
+func dayGate(when When, t time.Time, cal *Calendar) string {
+	switch {
+	case len(when.DayOfMonth) > 0 && !matchesShiftedDayOfMonth(when.DayOfMonth, when.Shift, t, cal):
+		return "day_of_month"
+	case len(when.DayOfMonthRanges) > 0 && !matchesDayOfMonthRange(when.DayOfMonthRanges, t.Day(), daysInMonth(t)):
+		return "day_of_month_range"
+	case len(when.BusinessDayOfMonth) > 0 && !matchesBusinessDayOfMonth(when.BusinessDayOfMonth, t, cal):
+		return "business_day_of_month"
+	case len(when.DaysOfWeek) > 0 && !matchesDayOfWeek(when.DaysOfWeek, t.Weekday()):
+		return "days_of_week"
+	case when.NthWeekday != "" && !matchesNthWeekday(when.NthWeekday, t):
+		return fmt.Sprintf("nth_weekday %q", when.NthWeekday)
+	}
+	return ""
+}
+
+// hasDayGates reports whether when restricts evaluation to certain days.
+func hasDayGates(when When) bool {
+	return len(when.DayOfMonth) > 0 || len(when.DaysOfWeek) > 0 || when.NthWeekday != "" ||
+		len(when.DayOfMonthRanges) > 0 || len(when.BusinessDayOfMonth) > 0
+}
+
 func sameMinute(a, b time.Time) bool {
 	return a.Year() == b.Year() && a.Month() == b.Month() && a.Day() == b.Day() && a.Hour() == b.Hour() && a.Minute() == b.Minute()
 }
@@ -3,6 +3,7 @@ package rules
 import (
 	"context"
 	"fmt"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -352,3 +353,203 @@ func containsAll(s string, parts []string) bool {
 	}
 	return true
 }
+
+func TestEvaluateRulesReportsCheckedRules(t *testing.T) {
+	rs := []Rule{
+		{Name: "firing", When: WhenList{{Condition: `account.balance("Checking") < 100`}}},
+		{Name: "ok", When: WhenList{{Condition: `account.balance("Checking") > 100`}}},
+		{Name: "gated", When: WhenList{{DayOfMonth: []int{1}, Condition: `account.balance("Checking") < 100`}}},
+	}
+	data := Data{
+		Accounts: map[string]int64{"Checking": 50_000},
+		Vars:     map[string]int64{},
+		Now:      time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC),
+	}
+	res, err := EvaluateRules(context.Background(), rs, nil, data)
+	if err != nil {
+		t.Fatalf("evaluate error: %v", err)
+	}
+	if len(res.Triggers) != 1 || res.Triggers[0].Rule.Name != "firing" {
+		t.Fatalf("expected only firing rule to trigger, got %+v", res.Triggers)
+	}
+	if strings.Join(res.Checked, ",") != "firing,ok" {
+		t.Fatalf("expected firing and ok to be checked, got %v", res.Checked)
+	}
+}
+
+func TestEvaluateRulesSkipsSilencedRules(t *testing.T) {
+	store, err := NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	now := time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC)
+	if err := store.Silence("snoozed", Silence{Until: now.Add(time.Hour), Reason: "payday Friday", CreatedAt: now}); err != nil {
+		t.Fatalf("silence: %v", err)
+	}
+	if err := store.Silence("muted", Silence{CreatedAt: now}); err != nil {
+		t.Fatalf("silence: %v", err)
+	}
+	low := WhenList{{Condition: `account.balance("Checking") < 100`}}
+	rs := []Rule{{Name: "snoozed", When: low}, {Name: "muted", When: low}, {Name: "live", When: low}}
+	data := Data{Accounts: map[string]int64{"Checking": 50_000}, Vars: map[string]int64{}, Now: now}
+
+	res, err := EvaluateRules(context.Background(), rs, store, data)
+	if err != nil {
+		t.Fatalf("evaluate error: %v", err)
+	}
+	if len(res.Triggers) != 1 || res.Triggers[0].Rule.Name != "live" {
+		t.Fatalf("expected only the live rule to trigger, got %+v", res.Triggers)
+	}
+	if strings.Join(res.Silenced, ",") != "snoozed,muted" {
+		t.Fatalf("unexpected silenced rules: %v", res.Silenced)
+	}
+
+	data.Now = now.Add(2 * time.Hour)
+	res, err = EvaluateRules(context.Background(), rs, store, data)
+	if err != nil {
+		t.Fatalf("evaluate error: %v", err)
+	}
+	if len(res.Triggers) != 2 {
+		t.Fatalf("expected the snooze to expire, got %+v", res.Triggers)
+	}
+	if _, ok := store.Silences()["snoozed"]; ok {
+		t.Fatalf("expected expired snooze to be removed")
+	}
+}
+
+func TestEvaluateForAndClearCondition(t *testing.T) {
+	store, err := NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	r := Rule{
+		Name: "hover",
+		When: WhenList{{
+			Condition:      `account.balance("Checking") < 100`,
+			For:            Duration(2 * time.Hour),
+			ClearCondition: `account.balance("Checking") > 150`,
+		}},
+	}
+	start := time.Date(2024, time.January, 15, 8, 0, 0, 0, time.UTC)
+	fires := func(balance int64, hours int) bool {
+		t.Helper()
+		data := Data{
+			Accounts: map[string]int64{"Checking": balance},
+			Vars:     map[string]int64{},
+			Now:      start.Add(time.Duration(hours) * time.Hour),
+		}
+		trigs, err := Evaluate(context.Background(), []Rule{r}, store, data)
+		if err != nil {
+			t.Fatalf("evaluate error: %v", err)
+		}
+		return len(trigs) == 1
+	}
+
+	for _, step := range []struct {
+		balance int64
+		hours   int
+		expect  bool
+	}{
+		{90_000, 0, false},  // pending
+		{120_000, 1, false}, // dipped back: pending resets
+		{90_000, 2, false},  // pending again
+		{90_000, 3, false},
+		{90_000, 4, true},   // held for 2h
+		{120_000, 5, true},  // above threshold but not clear yet
+		{160_000, 6, false}, // clear_condition resolves
+		{90_000, 7, false},  // must hold again before re-firing
+	} {
+		if got := fires(step.balance, step.hours); got != step.expect {
+			t.Fatalf("hour %d balance %d: expected %v got %v", step.hours, step.balance, step.expect, got)
+		}
+	}
+}
+
+func TestClauseStateFollowsReorderedClauses(t *testing.T) {
+	store, err := NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	held := When{Condition: `account.balance("Checking") < 100`, For: Duration(time.Hour)}
+	other := When{Condition: `account.balance("Savings") < 100`, For: Duration(time.Hour)}
+	start := time.Date(2024, time.January, 15, 8, 0, 0, 0, time.UTC)
+	fires := func(whens WhenList, hours int) bool {
+		t.Helper()
+		data := Data{
+			Accounts: map[string]int64{"Checking": 90_000, "Savings": 500_000},
+			Now:      start.Add(time.Duration(hours) * time.Hour),
+		}
+		trigs, err := Evaluate(context.Background(), []Rule{{Name: "r", When: whens}}, store, data)
+		if err != nil {
+			t.Fatalf("evaluate error: %v", err)
+		}
+		return len(trigs) == 1
+	}
+	if fires(WhenList{held}, 0) {
+		t.Fatalf("fired before for elapsed")
+	}
+	// a clause inserted ahead must not inherit or reset the pending state
+	if !fires(WhenList{other, held}, 1) {
+		t.Fatalf("expected pending state to follow the clause")
+	}
+}
+
+func TestSetClauseIgnoresReloadedTimes(t *testing.T) {
+	path := t.TempDir() + "/obs.json"
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	since := time.Now() // carries a monotonic reading and local location
+	if err := store.SetClause("r#1", ClauseState{PendingSince: since}); err != nil {
+		t.Fatalf("set clause: %v", err)
+	}
+	info, _ := os.Stat(path)
+	if err := store.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	os.Chtimes(path, info.ModTime().Add(-time.Hour), info.ModTime().Add(-time.Hour))
+	if err := store.SetClause("r#1", ClauseState{PendingSince: since}); err != nil {
+		t.Fatalf("set clause: %v", err)
+	}
+	if after, _ := os.Stat(path); !after.ModTime().Equal(info.ModTime().Add(-time.Hour)) {
+		t.Fatalf("unchanged clause state rewrote the store")
+	}
+}
+
+func TestEvaluateDateFunctions(t *testing.T) {
+	data := Data{
+		Accounts: map[string]int64{"Checking": 500_000},
+		Vars:     map[string]int64{},
+		Now:      time.Date(2024, time.February, 20, 15, 30, 0, 0, time.UTC), // Tuesday, leap year
+	}
+	for _, tt := range []struct {
+		cond   string
+		expect bool
+	}{
+		{`days_in_month() == 29`, true},
+		{`days_left_in_month() == 10`, true},
+		{`day_of_month() == 20`, true},
+		{`weekday() == "Tuesday"`, true},
+		{`days_until(25) == 5`, true},
+		{`days_until(20) == 0`, true},
+		{`days_until(5) == 14`, true},
+		{`days_until(-1) == 9`, true},
+		{`days_until(31) == 9`, true},
+		{`now().Hour() == 15 && today().Hour() == 0`, true},
+		{`days_between(today(), add_days(today(), 12)) == 12`, true},
+		{`account.balance("Checking") / days_left_in_month() < 40`, false},
+		{`account.balance("Checking") / days_left_in_month() < 60`, true},
+	} {
+		got, err := evaluateCondition(tt.cond, nil, nil, data)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.cond, err)
+		}
+		if got != tt.expect {
+			t.Fatalf("%s: expected %v got %v", tt.cond, tt.expect, got)
+		}
+	}
+	if _, err := evaluateCondition(`days_until(0) == 1`, nil, nil, data); err == nil {
+		t.Fatalf("expected error for days_until(0)")
+	}
+}
@@ -0,0 +1,283 @@
+package rules
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Shift directions for day_of_month targets that land on a non-business day.
+const (
+	ShiftPreviousBusinessDay = "previous_business_day"
+	ShiftNextBusinessDay     = "next_business_day"
+)
+
+const holidayLayout = "2006-01-02"
+
+// Calendar knows which days are holidays. Weekends are never business days;
+// a nil Calendar has no holidays.
+type Calendar struct {
+	holidays map[string]string // YYYY-MM-DD -> name
+}
+
+// NewCalendar returns an empty Calendar.
+func NewCalendar() *Calendar {
+	return &Calendar{holidays: map[string]string{}}
+}
+
+// Add marks day as a holiday.
+func (c *Calendar) Add(day time.Time, name string) {
+	c.holidays[day.Format(holidayLayout)] = name
+}
+
+// Len returns the number of holidays in the calendar.
+func (c *Calendar) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.holidays)
+}
+
+// Holiday returns the name of the holiday on t's calendar day, if any.
+func (c *Calendar) Holiday(t time.Time) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	name, ok := c.holidays[t.Format(holidayLayout)]
+	return name, ok
+}
+
+// IsBusinessDay reports whether t falls on a weekday that is not a holiday.
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	if wd := t.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		return false
+	}
+	_, holiday := c.Holiday(t)
+	return !holiday
+}
+
+// LoadCalendar reads holiday calendars from ICS files (.ics) or YAML lists
+// (.yaml, .yml, .json) into one Calendar.
+func LoadCalendar(paths ...string) (*Calendar, error) {
+	cal := NewCalendar()
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".ics":
+			err = cal.addICS(content)
+		case ".yaml", ".yml", ".json":
+			err = cal.addYAML(content)
+		default:
+			err = fmt.Errorf("unsupported calendar format (want .ics, .yaml or .json)")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("holidays %s: %w", path, err)
+		}
+	}
+	return cal, nil
+}
+
+// addYAML reads a list whose entries are either dates or {date, name} maps.
+func (c *Calendar) addYAML(content []byte) error {
+	var entries []yaml.Node
+	if err := yaml.Unmarshal(content, &entries); err != nil {
+		return err
+	}
+	for _, n := range entries {
+		var entry struct {
+			Date string `yaml:"date"`
+			Name string `yaml:"name"`
+		}
+		if n.Kind == yaml.ScalarNode {
+			entry.Date = n.Value
+		} else if err := n.Decode(&entry); err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		day, err := time.Parse(holidayLayout, strings.TrimSpace(entry.Date))
+		if err != nil {
+			return fmt.Errorf("line %d: invalid date %q, expected YYYY-MM-DD", n.Line, entry.Date)
+		}
+		c.Add(day, entry.Name)
+	}
+	return nil
+}
+
+// addICS reads the all-day VEVENTs of an iCalendar file. Multi-day events
+// mark every day up to their exclusive DTEND; recurrence rules are not
+// expanded.
+func (c *Calendar) addICS(content []byte) error {
+	var (
+		inEvent    bool
+		start, end time.Time
+		summary    string
+	)
+	for _, line := range unfoldICS(content) {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		prop, _, _ := strings.Cut(name, ";")
+		switch strings.ToUpper(prop) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				inEvent, start, end, summary = true, time.Time{}, time.Time{}, ""
+			}
+		case "DTSTART", "DTEND":
+			if !inEvent {
+				continue
+			}
+			if len(value) < 8 {
+				return fmt.Errorf("invalid %s %q", prop, value)
+			}
+			day, err := time.Parse("20060102", value[:8])
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", prop, value)
+			}
+			if strings.EqualFold(prop, "DTSTART") {
+				start = day
+			} else {
+				end = day
+			}
+		case "SUMMARY":
+			summary = value
+		case "END":
+			if !inEvent || !strings.EqualFold(value, "VEVENT") {
+				continue
+			}
+			inEvent = false
+			if start.IsZero() {
+				continue
+			}
+			c.Add(start, summary)
+			for d := start.AddDate(0, 0, 1); d.Before(end); d = d.AddDate(0, 0, 1) {
+				c.Add(d, summary)
+			}
+		}
+	}
+	return nil
+}
+
+// unfoldICS splits content into logical lines, joining RFC 5545 folded
+// continuation lines.
+func unfoldICS(content []byte) []string {
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(content))
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// nthBusinessDay returns the nth business day of month's month; negative n
+// counts from the end (-1 = last business day).
+func nthBusinessDay(month time.Time, n int, cal *Calendar) (time.Time, bool) {
+	if n == 0 {
+		return time.Time{}, false
+	}
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	day, step := first, 1
+	if n < 0 {
+		day, step, n = first.AddDate(0, 1, -1), -1, -n
+	}
+	for ; day.Month() == first.Month(); day = day.AddDate(0, 0, step) {
+		if cal.IsBusinessDay(day) {
+			n--
+			if n == 0 {
+				return day, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func matchesBusinessDayOfMonth(days []int, t time.Time, cal *Calendar) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, n := range days {
+		if day, ok := nthBusinessDay(t, n, cal); ok && sameCalendarDay(day, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// shiftToBusinessDay moves t to the nearest business day in the shift
+// direction; t is returned unchanged when it already is one or shift is empty.
+func shiftToBusinessDay(t time.Time, shift string, cal *Calendar) time.Time {
+	step := 0
+	switch shift {
+	case ShiftPreviousBusinessDay:
+		step = -1
+	case ShiftNextBusinessDay:
+		step = 1
+	default:
+		return t
+	}
+	for i := 0; i < 31 && !cal.IsBusinessDay(t); i++ {
+		t = t.AddDate(0, 0, step)
+	}
+	return t
+}
+
+// matchesShiftedDayOfMonth is matchesDayOfMonth with each target day moved
+// off weekends and holidays. Shifted targets may cross into an adjacent
+// month, so the neighbouring months' targets are checked too.
+func matchesShiftedDayOfMonth(days []int, shift string, t time.Time, cal *Calendar) bool {
+	if shift == "" {
+		return matchesDayOfMonth(days, t.Day(), daysInMonth(t))
+	}
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	for _, offset := range []int{-1, 0, 1} {
+		month := first.AddDate(0, offset, 0)
+		last := daysInMonth(month)
+		for _, d := range days {
+			if d < 0 {
+				d = last + d + 1
+			}
+			if d < 1 || d > last {
+				continue
+			}
+			target := time.Date(month.Year(), month.Month(), d, 0, 0, 0, 0, t.Location())
+			if sameCalendarDay(shiftToBusinessDay(target, shift, cal), t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseBusinessDayCapture parses capture_on values such as "last business
+// day", "first business day" or "business day 3" into a business day index.
+func parseBusinessDayCapture(val string) (int, bool) {
+	fields := strings.Fields(strings.ToLower(val))
+	switch {
+	case len(fields) == 3 && fields[1] == "business" && fields[2] == "day":
+		switch fields[0] {
+		case "first":
+			return 1, true
+		case "last":
+			return -1, true
+		}
+	case len(fields) == 3 && fields[0] == "business" && fields[1] == "day":
+		if n, err := strconv.Atoi(fields[2]); err == nil && n != 0 && n >= -23 && n <= 23 {
+			return n, true
+		}
+	}
+	return 0, false
+}
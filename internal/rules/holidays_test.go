@@ -0,0 +1,109 @@
+package rules
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadCalendarICSAndYAML(t *testing.T) {
+	dir := t.TempDir()
+	ics := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20241225\r\nDTEND;VALUE=DATE:20241227\r\nSUMMARY:Christmas\r\n  break\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	if err := os.WriteFile(filepath.Join(dir, "bank.ics"), []byte(ics), 0o644); err != nil {
+		t.Fatalf("write ics: %v", err)
+	}
+	list := "- 2024-11-28\n- date: 2024-07-04\n  name: Independence Day\n"
+	if err := os.WriteFile(filepath.Join(dir, "extra.yaml"), []byte(list), 0o644); err != nil {
+		t.Fatalf("write yaml: %v", err)
+	}
+	cal, err := LoadCalendar(filepath.Join(dir, "bank.ics"), filepath.Join(dir, "extra.yaml"))
+	if err != nil {
+		t.Fatalf("load calendar: %v", err)
+	}
+	if cal.Len() != 4 {
+		t.Fatalf("expected 4 holidays, got %d", cal.Len())
+	}
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 12, 0, 0, 0, time.UTC) }
+	if name, ok := cal.Holiday(day(time.December, 26)); !ok || name != "Christmas break" {
+		t.Fatalf("expected multi-day event with folded summary, got %q %v", name, ok)
+	}
+	if _, ok := cal.Holiday(day(time.December, 27)); ok {
+		t.Fatalf("DTEND should be exclusive")
+	}
+	if cal.IsBusinessDay(day(time.July, 4)) || cal.IsBusinessDay(day(time.July, 6)) || !cal.IsBusinessDay(day(time.July, 5)) {
+		t.Fatalf("unexpected business days around July 4th")
+	}
+}
+
+func TestEvaluateBusinessDayGates(t *testing.T) {
+	cal := NewCalendar()
+	cal.Add(time.Date(2024, time.November, 28, 0, 0, 0, 0, time.UTC), "Thanksgiving")
+	cal.Add(time.Date(2024, time.November, 29, 0, 0, 0, 0, time.UTC), "Day after")
+	cond := `account.balance("Checking") < 100`
+	rs := []Rule{
+		{Name: "last_bd", When: WhenList{{BusinessDayOfMonth: []int{-1}, Condition: cond}}},
+		{Name: "first_bd", When: WhenList{{BusinessDayOfMonth: []int{1}, Condition: cond}}},
+		// the 15th of June 2024 is a Saturday
+		{Name: "shift_prev", When: WhenList{{DayOfMonth: []int{15}, Shift: ShiftPreviousBusinessDay, Condition: cond}}},
+		{Name: "shift_next", When: WhenList{{DayOfMonth: []int{15}, Shift: ShiftNextBusinessDay, Condition: cond}}},
+	}
+	fired := func(now time.Time) map[string]bool {
+		t.Helper()
+		data := Data{Accounts: map[string]int64{"Checking": 50_000}, Vars: map[string]int64{}, Now: now, Calendar: cal}
+		trigs, err := Evaluate(context.Background(), rs, nil, data)
+		if err != nil {
+			t.Fatalf("evaluate error: %v", err)
+		}
+		out := map[string]bool{}
+		for _, tr := range trigs {
+			out[tr.Rule.Name] = true
+		}
+		return out
+	}
+	at := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 9, 0, 0, 0, time.UTC) }
+
+	if got := fired(at(time.November, 27)); !got["last_bd"] || len(got) != 1 {
+		t.Fatalf("Nov 27 should be the last business day (holidays 28-29, weekend 30), got %v", got)
+	}
+	if got := fired(at(time.November, 1)); !got["first_bd"] || len(got) != 1 {
+		t.Fatalf("Nov 1 should be the first business day, got %v", got)
+	}
+	if got := fired(at(time.June, 14)); !got["shift_prev"] || got["shift_next"] {
+		t.Fatalf("June 14 should match previous_business_day shift only, got %v", got)
+	}
+	if got := fired(at(time.June, 17)); !got["shift_next"] || got["shift_prev"] {
+		t.Fatalf("June 17 should match next_business_day shift only, got %v", got)
+	}
+	if got := fired(at(time.June, 15)); got["shift_prev"] || got["shift_next"] {
+		t.Fatalf("shifted rules should not fire on the weekend target, got %v", got)
+	}
+}
+
+func TestCaptureOnLastBusinessDay(t *testing.T) {
+	store, err := NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	r := Rule{
+		Name:    "statement",
+		Observe: ObserveList{{CaptureOn: "last business day", Variable: "stmt", Value: `account.balance("Card")`}},
+	}
+	capture := func(now time.Time) bool {
+		t.Helper()
+		data := Data{Accounts: map[string]int64{"Card": 42_000}, Vars: map[string]int64{}, Now: now}
+		if _, err := Evaluate(context.Background(), []Rule{r}, store, data); err != nil {
+			t.Fatalf("evaluate error: %v", err)
+		}
+		_, ok := store.Get("stmt")
+		return ok
+	}
+	// August 31 2024 is a Saturday; the last business day is Friday the 30th.
+	if capture(time.Date(2024, time.August, 31, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("should not capture on the last calendar day")
+	}
+	if !capture(time.Date(2024, time.August, 30, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected capture on the last business day")
+	}
+}
@@ -1,20 +1,77 @@
 package rules
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/robfig/cron/v3"
+
+	"ynab-alerts/internal/daytime"
 )
 
-// LintResult captures issues and metadata about a rule.
+// LintOptions tunes how lint approximates evaluation.
+type LintOptions struct {
+	PollInterval time.Duration
+	Calendar     *Calendar // holidays for business-day gates (optional)
+	Accounts     []string  // known account names; nil skips the account check
+	CachedNames  bool      // Accounts come from the last poll, so unknown names only warn
+	Channels     []string  // configured notifier channels; nil skips the notify check
+}
+
+// LintResult captures issues and metadata about a rule. A def that does not
+// compile gets its own result named def.<name>, without severity or next
+// evaluation.
 type LintResult struct {
-	Name     string
-	Issues   []string
-	NextEval time.Time
-	HasNext  bool
+	Name        string
+	Severity    string
+	Pos         Position     // where the rule is defined
+	Issues      []string     // messages of Diagnostics, in order
+	Diagnostics []Diagnostic // issues with their level and position
+	NextEval    time.Time
+	HasNext     bool
+}
+
+// Lint diagnostic levels.
+const (
+	LintError   = "error"   // the rule is invalid or will fail at runtime
+	LintWarning = "warning" // the rule works but likely not as intended
+)
+
+// Diagnostic is one lint finding.
+type Diagnostic struct {
+	Level   string
+	Message string
+	Pos     Position
+}
+
+// Errors reports how many of the result's diagnostics are errors.
+func (r LintResult) Errors() int {
+	n := 0
+	for _, d := range r.Diagnostics {
+		if d.Level == LintError {
+			n++
+		}
+	}
+	return n
+}
+
+func lintError(pos Position, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{Level: LintError, Message: fmt.Sprintf(format, args...), Pos: pos}
+}
+
+func lintWarning(pos Position, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{Level: LintWarning, Message: fmt.Sprintf(format, args...), Pos: pos}
+}
+
+// orPos returns pos, or fallback when pos is unknown.
+func orPos(pos, fallback Position) Position {
+	if pos.Line == 0 {
+		return fallback
+	}
+	return pos
 }
 
 // Lint reads rules from dir and produces lint results.
@@ -24,89 +81,176 @@ func Lint(dir string, now time.Time) ([]LintResult, error) {
 
 // LintWithPoll reads rules from dir and produces lint results using pollInterval to approximate next eval times.
 func LintWithPoll(dir string, now time.Time, pollInterval time.Duration) ([]LintResult, error) {
-	rules, err := LoadDir(dir)
+	return LintWithOptions(dir, now, LintOptions{PollInterval: pollInterval})
+}
+
+// LoadFailure reports an error that stopped dir's rules from loading as a
+// lint result, so it can be written in any lint output format. Parse errors
+// keep their position.
+func LoadFailure(err error) LintResult {
+	var pos Position
+	var pe *parseError
+	if errors.As(err, &pe) {
+		pos = pe.Pos
+	}
+	d := lintError(pos, "%v", err)
+	return LintResult{Name: "load", Issues: []string{d.Message}, Diagnostics: []Diagnostic{d}}
+}
+
+// LintWithOptions reads rules from dir and produces lint results.
+func LintWithOptions(dir string, now time.Time, opts LintOptions) ([]LintResult, error) {
+	rules, scopes, err := loadDir(dir)
 	if err != nil {
 		return nil, err
 	}
-	nameSeen := map[string]struct{}{}
+	nameSeen := map[string]Position{}
 	var results []LintResult
 	for _, r := range rules {
 		variables := map[string]struct{}{}
-		res := LintResult{Name: r.Name}
+		res := LintResult{Name: r.Name, Severity: r.Level(), Pos: r.Pos}
+		var issues []Diagnostic
+		pos := r.Pos
 		if r.Name == "" {
-			res.Issues = append(res.Issues, "rule has no name")
+			issues = append(issues, lintError(pos, "rule has no name"))
+		}
+		if first, exists := nameSeen[r.Name]; exists && r.Name != "" {
+			issues = append(issues, lintError(pos, "duplicate rule name, first defined at %s", first))
+		} else {
+			nameSeen[r.Name] = pos
 		}
-		if _, exists := nameSeen[r.Name]; exists && r.Name != "" {
-			res.Issues = append(res.Issues, "duplicate rule name")
+		for _, k := range r.unknownKeys() {
+			issues = append(issues, lintError(orPos(k.Pos, pos), "%s", k))
 		}
-		nameSeen[r.Name] = struct{}{}
 
 		for _, obs := range r.Observe {
 			if obs.Variable == "" {
-				res.Issues = append(res.Issues, "observe variable is empty")
+				issues = append(issues, lintError(orPos(obs.Pos, pos), "observe variable is empty"))
 			} else {
 				variables[obs.Variable] = struct{}{}
 			}
+			issues = append(issues, lintCaptureOn(obs, orPos(obs.Pos, pos))...)
 		}
 
-		res.Issues = append(res.Issues, lintWhen(r.When, variables)...)
-		res.NextEval, res.HasNext = nextEval(r.When, now, pollInterval)
+		for _, err := range r.compile(nil) {
+			issues = append(issues, lintExprIssue(err, pos))
+		}
+		if opts.Accounts != nil {
+			issues = append(issues, lintAccounts(r, opts.Accounts, opts.CachedNames)...)
+		}
+		if opts.Channels != nil {
+			issues = append(issues, lintNotify(r, opts.Channels)...)
+		}
+		issues = append(issues, lintWhen(r.When, r.scope, variables, pos)...)
+		issues = append(issues, lintWindows(r.When, opts.PollInterval, pos)...)
+		issues = append(issues, lintPushover(r.Pushover, pos)...)
+		if strings.TrimSpace(r.QuietHours) != "" {
+			if _, err := daytime.ParseRange(r.QuietHours); err != nil {
+				issues = append(issues, lintError(pos, "quiet_hours: %v", err))
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(r.Severity)) {
+		case "", SeverityInfo, SeverityWarning, SeverityCritical:
+		default:
+			issues = append(issues, lintError(pos, "severity %q is invalid (info|warning|critical)", r.Severity))
+		}
+		switch strings.ToLower(strings.TrimSpace(r.Delivery)) {
+		case "", DeliveryImmediate, DeliveryDigest:
+		default:
+			issues = append(issues, lintError(pos, "delivery %q is invalid (immediate|digest)", r.Delivery))
+		}
+		if err := r.resolveTimezone(); err != nil {
+			issues = append(issues, lintError(pos, "%v", err))
+		}
+		res.Diagnostics = issues
+		for _, d := range issues {
+			res.Issues = append(res.Issues, d.Message)
+		}
+		res.NextEval, res.HasNext = nextEval(r.When, r.In(now), opts.PollInterval, opts.Calendar)
 		results = append(results, res)
 	}
+	// defs are checked on their own so unused ones are reported too
+	for _, err := range checkDefs(scopes) {
+		var de *DefError
+		if !errors.As(err, &de) {
+			continue
+		}
+		d := lintError(de.Pos, "def does not compile: %s", exprMessage(de.Err))
+		results = append(results, LintResult{Name: "def." + de.Name, Pos: de.Pos, Issues: []string{d.Message}, Diagnostics: []Diagnostic{d}})
+	}
 	return results, nil
 }
 
-func lintWhen(whens WhenList, vars map[string]struct{}) []string {
-	var issues []string
+func lintWhen(whens WhenList, sc *scope, vars map[string]struct{}, rulePos Position) []Diagnostic {
+	var issues []Diagnostic
 
 	if len(whens) == 0 {
-		issues = append(issues, "no when clause defined; rule will never run")
+		issues = append(issues, lintWarning(rulePos, "no when clause defined; rule will never run"))
 	}
 
 	for _, when := range whens {
+		pos := orPos(when.Pos, rulePos)
 		if when.Condition == "" {
-			issues = append(issues, "condition is empty; rule will never fire")
+			issues = append(issues, lintWarning(pos, "condition is empty; rule will never fire"))
 		}
 		if len(when.DayOfMonth) > 0 {
 			for _, d := range when.DayOfMonth {
 				if d == 0 || d < -31 || d > 31 {
-					issues = append(issues, fmt.Sprintf("day_of_month value %d is out of range -31..-1 or 1..31", d))
+					issues = append(issues, lintError(pos, "day_of_month value %d is out of range -31..-1 or 1..31", d))
 				}
 			}
 		}
 		for _, r := range when.DayOfMonthRanges {
 			s, e, ok := parseRange(r)
 			if !ok {
-				issues = append(issues, fmt.Sprintf("day_of_month_range value %q is invalid", r))
+				issues = append(issues, lintError(pos, "day_of_month_range value %q is invalid", r))
 				continue
 			}
 			if s < 1 || s > 31 || e < 1 || e > 31 {
-				issues = append(issues, fmt.Sprintf("day_of_month_range %q values must be within 1..31", r))
+				issues = append(issues, lintError(pos, "day_of_month_range %q values must be within 1..31", r))
 			}
 		}
 		for _, d := range when.DaysOfWeek {
 			if _, ok := weekdayMap[strings.ToLower(strings.TrimSpace(d))]; !ok {
-				issues = append(issues, fmt.Sprintf("days_of_week value %q is invalid", d))
+				issues = append(issues, lintError(pos, "days_of_week value %q is invalid", d))
 			}
 		}
 		if when.NthWeekday != "" {
 			if _, _, _, ok := parseNthWeekday(when.NthWeekday); !ok {
-				issues = append(issues, fmt.Sprintf("nth_weekday value %q is invalid", when.NthWeekday))
+				issues = append(issues, lintError(pos, "nth_weekday value %q is invalid", when.NthWeekday))
 			}
 		}
+		for _, n := range when.BusinessDayOfMonth {
+			if n == 0 || n < -23 || n > 23 {
+				issues = append(issues, lintError(pos, "business_day_of_month value %d is out of range -23..-1 or 1..23", n))
+			}
+		}
+		issues = append(issues, lintShift(when.Shift, len(when.DayOfMonth) > 0, "day_of_month", pos)...)
 
 		if when.Schedule != "" {
 			if _, err := cron.ParseStandard(when.Schedule); err != nil {
-				issues = append(issues, fmt.Sprintf("schedule invalid cron: %v", err))
+				issues = append(issues, lintError(pos, "schedule invalid cron: %v", err))
 			}
-			if len(when.DayOfMonth) > 0 || len(when.DaysOfWeek) > 0 || when.NthWeekday != "" || len(when.DayOfMonthRanges) > 0 {
-				issues = append(issues, "schedule present; day/week gates will be ignored")
+			if len(when.DayOfMonth) > 0 || len(when.DaysOfWeek) > 0 || when.NthWeekday != "" || len(when.DayOfMonthRanges) > 0 || len(when.BusinessDayOfMonth) > 0 {
+				issues = append(issues, lintWarning(pos, "schedule present; day/week gates will be ignored"))
 			}
 		}
 
-		for _, ref := range varRefs(when.Condition) {
+		for _, ref := range varRefs(sc.expand(when.Condition)) {
 			if _, ok := vars[ref]; !ok {
-				issues = append(issues, fmt.Sprintf("condition references unknown variable %q", ref))
+				issues = append(issues, lintWarning(pos, "condition references unknown variable %q", ref))
+			}
+		}
+		if when.For < 0 {
+			issues = append(issues, lintError(pos, "for must not be negative"))
+		}
+		if when.ClearCondition != "" {
+			if when.Condition == "" {
+				issues = append(issues, lintWarning(pos, "clear_condition has no condition to clear"))
+			}
+			for _, ref := range varRefs(sc.expand(when.ClearCondition)) {
+				if _, ok := vars[ref]; !ok {
+					issues = append(issues, lintWarning(pos, "clear_condition references unknown variable %q", ref))
+				}
 			}
 		}
 	}
@@ -114,6 +258,205 @@ func lintWhen(whens WhenList, vars map[string]struct{}) []string {
 	return issues
 }
 
+// lintExprIssue words a compile error without repeating the rule name,
+// positioned at the offending expression when known.
+func lintExprIssue(err error, rulePos Position) Diagnostic {
+	var ee *ExprError
+	if !errors.As(err, &ee) {
+		return lintError(rulePos, "%v", err)
+	}
+	return lintError(orPos(ee.Pos, rulePos), "%s does not compile: %s", ee.Field, exprMessage(ee.Err))
+}
+
+// lintNotify warns about notify entries that name no configured channel,
+// since the daemon skips them (or, when none resolve, sends to every
+// channel instead).
+func lintNotify(r Rule, channels []string) []Diagnostic {
+	var issues []Diagnostic
+	for _, name := range r.Notify {
+		known := false
+		for _, c := range channels {
+			if c == name {
+				known = true
+				break
+			}
+		}
+		if known {
+			continue
+		}
+		issue := fmt.Sprintf("notify channel %q is not configured (%s)", name, strings.Join(channels, "|"))
+		if match, ok := closestName(name, channels); ok {
+			issue += fmt.Sprintf("; did you mean %q?", match)
+		}
+		issues = append(issues, lintWarning(r.Pos, "%s", issue))
+	}
+	return issues
+}
+
+// lintAccounts reports account references that match no known account,
+// suggesting the closest name, and accounts.* match patterns that select
+// none. Names checked against the cached list may belong to an account added
+// since the last poll, so they are warnings until the cache refreshes.
+func lintAccounts(r Rule, known []string, cached bool) []Diagnostic {
+	names := make(map[string]bool, len(known))
+	for _, n := range known {
+		names[n] = true
+	}
+	var issues []Diagnostic
+	for _, ref := range r.AccountRefs() {
+		if names[ref] {
+			continue
+		}
+		issue := fmt.Sprintf("account %q not found", ref)
+		if cached {
+			issue += " in the accounts cached by the last poll"
+		}
+		if match, ok := closestName(ref, known); ok {
+			issue += fmt.Sprintf(" (did you mean %q?)", match)
+		}
+		if cached {
+			issues = append(issues, lintWarning(r.Pos, "%s", issue))
+		} else {
+			issues = append(issues, lintError(r.Pos, "%s", issue))
+		}
+	}
+	for _, pattern := range r.accountPatterns() {
+		matched := false
+		for _, n := range known {
+			if ok, _ := globMatch(pattern, n); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			issues = append(issues, lintWarning(r.Pos, "accounts match %q matches no account", pattern))
+		}
+	}
+	return issues
+}
+
+// closestName returns the candidate nearest to name by case-insensitive edit
+// distance, if it is close enough to be a likely typo.
+func closestName(name string, candidates []string) (string, bool) {
+	best, bestDist := "", -1
+	for _, c := range candidates {
+		d := editDistance(strings.ToLower(name), strings.ToLower(c))
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	limit := len([]rune(name)) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	return best, bestDist >= 0 && bestDist <= limit
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(br)]
+}
+
+func lintWindows(whens WhenList, pollInterval time.Duration, rulePos Position) []Diagnostic {
+	var issues []Diagnostic
+	for _, when := range whens {
+		if strings.TrimSpace(when.Window) == "" {
+			continue
+		}
+		pos := orPos(when.Pos, rulePos)
+		w, err := parseWindow(when.Window)
+		if err != nil {
+			issues = append(issues, lintError(pos, "%s", err.Error()))
+			continue
+		}
+		if when.Schedule != "" {
+			issues = append(issues, lintWarning(pos, "schedule present; window will be ignored"))
+		}
+		if pollInterval > w.Length() {
+			issues = append(issues, lintWarning(pos, "window %q is shorter than the poll interval %s; clause may be skipped", when.Window, pollInterval))
+		}
+	}
+	return issues
+}
+
+func lintShift(shift string, hasDays bool, field string, pos Position) []Diagnostic {
+	switch shift {
+	case "":
+		return nil
+	case ShiftPreviousBusinessDay, ShiftNextBusinessDay:
+		if !hasDays {
+			return []Diagnostic{lintWarning(pos, "shift has no %s to move", field)}
+		}
+		return nil
+	default:
+		return []Diagnostic{lintError(pos, "shift %q is invalid (previous_business_day|next_business_day)", shift)}
+	}
+}
+
+func lintCaptureOn(obs Observe, pos Position) []Diagnostic {
+	var issues []Diagnostic
+	sched, err := parseCaptureOn(obs)
+	if err != nil {
+		issues = append(issues, lintError(pos, "%s", err.Error()))
+	}
+	hasDay := err == nil && len(sched.gate.DayOfMonth) > 0
+	issues = append(issues, lintShift(obs.Shift, hasDay, "capture_on day", pos)...)
+	switch strings.ToLower(strings.TrimSpace(obs.CaptureOncePer)) {
+	case "", PerDay, PerWeek, PerMonth:
+	default:
+		issues = append(issues, lintError(pos, "capture_once_per %q is invalid (day|week|month)", obs.CaptureOncePer))
+	}
+	if obs.CatchUp && err == nil && (sched.always || sched.gate.Schedule != "") {
+		issues = append(issues, lintWarning(pos, "catch_up only applies to day-based capture_on values"))
+	}
+	return issues
+}
+
+func lintPushover(p *PushoverOptions, pos Position) []Diagnostic {
+	if p == nil {
+		return nil
+	}
+	var issues []Diagnostic
+	priority := 0
+	if p.Priority != nil {
+		priority = *p.Priority
+	}
+	if priority < -2 || priority > 2 {
+		issues = append(issues, lintError(pos, "pushover priority %d is out of range -2..2", priority))
+	}
+	if priority == 2 {
+		if p.Retry != 0 && time.Duration(p.Retry) < 30*time.Second {
+			issues = append(issues, lintError(pos, "pushover retry must be at least 30s"))
+		}
+		if time.Duration(p.Expire) > 3*time.Hour {
+			issues = append(issues, lintError(pos, "pushover expire must be at most 3h"))
+		}
+	} else if p.Retry != 0 || p.Expire != 0 {
+		issues = append(issues, lintWarning(pos, "pushover retry/expire only apply to emergency priority 2"))
+	}
+	if p.URLTitle != "" && p.URL == "" {
+		issues = append(issues, lintWarning(pos, "pushover url_title set without url"))
+	}
+	return issues
+}
+
 var varRefPattern = regexp.MustCompile(`var\.([A-Za-z0-9_]+)`)
 
 func varRefs(cond string) []string {
@@ -127,7 +470,7 @@ func varRefs(cond string) []string {
 	return out
 }
 
-func nextEval(whens WhenList, now time.Time, pollInterval time.Duration) (time.Time, bool) {
+func nextEval(whens WhenList, now time.Time, pollInterval time.Duration, cal *Calendar) (time.Time, bool) {
 	if len(whens) == 0 {
 		return time.Time{}, false
 	}
@@ -152,7 +495,7 @@ func nextEval(whens WhenList, now time.Time, pollInterval time.Duration) (time.T
 	// if no explicit gates anywhere: now + poll
 	allUngated := true
 	for _, when := range whens {
-		if len(when.DayOfMonth) > 0 || len(when.DaysOfWeek) > 0 || when.NthWeekday != "" || len(when.DayOfMonthRanges) > 0 {
+		if hasDayGates(when) || strings.TrimSpace(when.Window) != "" {
 			allUngated = false
 			break
 		}
@@ -163,27 +506,52 @@ func nextEval(whens WhenList, now time.Time, pollInterval time.Duration) (time.T
 
 	for i := 0; i <= 365; i++ {
 		t := now.AddDate(0, 0, i)
+		var best time.Time
 		for _, when := range whens {
-			if matchesDayOfMonth(when.DayOfMonth, t.Day(), daysInMonth(t)) &&
-				matchesDayOfMonthRange(when.DayOfMonthRanges, t.Day(), daysInMonth(t)) &&
-				matchesDayOfWeek(when.DaysOfWeek, t.Weekday()) &&
-				matchNth(when.NthWeekday, t) {
-				approx := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location()).Add(pollInterval)
-				if approx.Before(now) {
-					approx = now.Add(pollInterval)
-				}
-				return approx, true
+			if dayGate(when, t, cal) != "" {
+				continue
 			}
+			if approx, ok := firstEvalOn(when, t, now, pollInterval); ok && (best.IsZero() || approx.Before(best)) {
+				best = approx
+			}
+		}
+		if !best.IsZero() {
+			return best, true
 		}
 	}
 	return time.Time{}, false
 }
 
-func matchNth(expr string, t time.Time) bool {
-	if expr == "" {
-		return true
+// firstEvalOn approximates the first evaluation of when on day: one poll
+// interval after midnight or after its window opens, and no earlier than one
+// poll interval from now.
+func firstEvalOn(when When, day, now time.Time, pollInterval time.Duration) (time.Time, bool) {
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, now.Location())
+	if strings.TrimSpace(when.Window) == "" {
+		approx := midnight.Add(pollInterval)
+		if approx.Before(now) {
+			approx = now.Add(pollInterval)
+		}
+		return approx, true
+	}
+	w, err := parseWindow(when.Window)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if sameCalendarDay(day, now) && w.Contains(now.Add(pollInterval)) {
+		return now.Add(pollInterval), true
+	}
+	if !w.onDay(day.Weekday()) {
+		return time.Time{}, false
+	}
+	approx := w.OpensOn(midnight)
+	if pollInterval < w.Length() {
+		approx = approx.Add(pollInterval)
+	}
+	if approx.Before(now) {
+		return time.Time{}, false
 	}
-	return matchesNthWeekday(expr, t)
+	return approx, true
 }
 
 func nthWeekdayParsable(expr string) bool {
@@ -118,3 +118,218 @@ func TestLintDayOfMonthRangeValidation(t *testing.T) {
 		t.Fatalf("expected range issues, got: %+v", results[0].Issues)
 	}
 }
+
+func TestLintPushoverOptions(t *testing.T) {
+	dir := t.TempDir()
+	content := `
+- name: emergency
+  when:
+    condition: account.balance("Checking") < 100
+  pushover:
+    priority: 2
+    retry: 10s
+    expire: 4h
+    url_title: Budget
+- name: fine
+  when:
+    condition: account.balance("Checking") < 100
+  pushover:
+    priority: -1
+    sound: cashregister
+    ttl: 1h
+`
+	if err := os.WriteFile(filepath.Join(dir, "r.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	results, err := LintWithPoll(dir, time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), time.Hour)
+	if err != nil {
+		t.Fatalf("lint error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if len(results[0].Issues) != 3 {
+		t.Fatalf("expected retry, expire and url_title issues, got %v", results[0].Issues)
+	}
+	if len(results[1].Issues) != 0 {
+		t.Fatalf("expected no issues, got %v", results[1].Issues)
+	}
+}
+
+func TestLintSeverity(t *testing.T) {
+	dir := t.TempDir()
+	content := `
+- name: default
+  when:
+    condition: account.balance("Checking") < 100
+- name: critical
+  severity: Critical
+  when:
+    condition: account.balance("Checking") < 100
+- name: bogus
+  severity: urgent
+  when:
+    condition: account.balance("Checking") < 100
+`
+	if err := os.WriteFile(filepath.Join(dir, "r.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	results, err := LintWithPoll(dir, time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), time.Hour)
+	if err != nil {
+		t.Fatalf("lint error: %v", err)
+	}
+	if results[0].Severity != SeverityWarning || results[1].Severity != SeverityCritical {
+		t.Fatalf("unexpected severities: %q %q", results[0].Severity, results[1].Severity)
+	}
+	if len(results[2].Issues) != 1 {
+		t.Fatalf("expected invalid severity issue, got %v", results[2].Issues)
+	}
+}
+
+func TestLintBusinessDayGates(t *testing.T) {
+	dir := t.TempDir()
+	content := `
+- name: last_business_day
+  when:
+    business_day_of_month: [-1]
+    condition: account.balance("Checking") < 100
+- name: bad
+  observe:
+    capture_on: "someday"
+    variable: x
+    value: 1
+  when:
+    shift: sideways
+    business_day_of_month: [0]
+    condition: account.balance("Checking") < 100
+- name: scheduled_business_day
+  when:
+    schedule: "0 9 * * *"
+    business_day_of_month: [1]
+    condition: account.balance("Checking") < 100
+`
+	if err := os.WriteFile(filepath.Join(dir, "r.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	cal := NewCalendar()
+	cal.Add(time.Date(2024, time.May, 31, 0, 0, 0, 0, time.UTC), "Bank holiday")
+	now := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	results, err := LintWithOptions(dir, now, LintOptions{PollInterval: time.Hour, Calendar: cal})
+	if err != nil {
+		t.Fatalf("lint error: %v", err)
+	}
+	want := time.Date(2024, time.May, 30, 1, 0, 0, 0, time.UTC)
+	if !results[0].HasNext || !results[0].NextEval.Equal(want) {
+		t.Fatalf("expected next eval %s, got %s", want, results[0].NextEval)
+	}
+	if len(results[1].Issues) != 3 {
+		t.Fatalf("expected capture_on, shift and business day issues, got %v", results[1].Issues)
+	}
+	if !hasDiagnostic(results[2], "schedule present; day/week gates will be ignored") {
+		t.Fatalf("expected schedule conflict warning for business_day_of_month, got %v", results[2].Issues)
+	}
+}
+
+func TestLintChecksAccountNames(t *testing.T) {
+	dir := t.TempDir()
+	content := `
+- name: typo
+  observe:
+    variable: start
+    value: account.balance("Savings")
+  when:
+    condition: account.balance("Chekcing") < var.start
+    clear_condition: account.balance("Brokerage") > 100
+`
+	if err := os.WriteFile(filepath.Join(dir, "r.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	now := time.Date(2024, time.March, 10, 9, 0, 0, 0, time.UTC)
+	opts := LintOptions{PollInterval: time.Minute, Accounts: []string{"Checking", "Savings", "CC_Main"}}
+	results, err := LintWithOptions(dir, now, opts)
+	if err != nil {
+		t.Fatalf("lint error: %v", err)
+	}
+	issues := strings.Join(results[0].Issues, "\n")
+	if !strings.Contains(issues, `account "Chekcing" not found (did you mean "Checking"?)`) {
+		t.Fatalf("expected suggestion for misspelled account, got %v", results[0].Issues)
+	}
+	if !strings.Contains(issues, `account "Brokerage" not found`) || strings.Contains(issues, `"Brokerage" not found (did you mean`) {
+		t.Fatalf("expected unknown account without suggestion, got %v", results[0].Issues)
+	}
+	if strings.Contains(issues, `"Savings"`) {
+		t.Fatalf("known account should not be reported, got %v", results[0].Issues)
+	}
+
+	opts.CachedNames = true
+	results, err = LintWithOptions(dir, now, opts)
+	if err != nil {
+		t.Fatalf("lint error: %v", err)
+	}
+	if results[0].Errors() != 0 || !hasDiagnostic(results[0], `account "Brokerage" not found in the accounts cached by the last poll`) {
+		t.Fatalf("expected cached account misses as warnings, got %+v", results[0].Diagnostics)
+	}
+
+	results, err = LintWithOptions(dir, now, LintOptions{PollInterval: time.Minute})
+	if err != nil {
+		t.Fatalf("lint error: %v", err)
+	}
+	if len(results[0].Issues) != 0 {
+		t.Fatalf("expected no account check without known names, got %v", results[0].Issues)
+	}
+}
+
+func TestLintDiagnosticLevelsAndPositions(t *testing.T) {
+	dir := t.TempDir()
+	content := `- name: gated
+  when:
+    - condition: account.balance("Checking") < 10
+    - schedule: "0 9 14 * *"
+      day_of_month: [1]
+      condition: account.balance("Checking") < 10
+- name: broken
+  severity: loud
+  when:
+    condition: account.balance("Checking") < 10
+`
+	if err := os.WriteFile(filepath.Join(dir, "r.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	results, err := LintWithPoll(dir, time.Date(2024, time.March, 10, 9, 0, 0, 0, time.UTC), time.Minute)
+	if err != nil {
+		t.Fatalf("lint error: %v", err)
+	}
+	gated, broken := results[0], results[1]
+	if gated.Pos != (Position{File: "r.yaml", Line: 1, Column: 3}) || broken.Pos.Line != 7 {
+		t.Fatalf("unexpected rule positions %v / %v", gated.Pos, broken.Pos)
+	}
+	if gated.Errors() != 0 || len(gated.Diagnostics) != 1 {
+		t.Fatalf("expected a single warning for gated, got %+v", gated.Diagnostics)
+	}
+	if d := gated.Diagnostics[0]; d.Level != LintWarning || d.Pos.Line != 4 {
+		t.Fatalf("expected warning on the second when clause, got %+v", d)
+	}
+	if broken.Errors() != 1 || broken.Diagnostics[0].Pos.Line != 7 {
+		t.Fatalf("expected severity error at the rule, got %+v", broken.Diagnostics)
+	}
+	if len(broken.Issues) != 1 || broken.Issues[0] != broken.Diagnostics[0].Message {
+		t.Fatalf("expected Issues to mirror diagnostics, got %v", broken.Issues)
+	}
+}
+
+func TestLintNotifyChannels(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"r.yaml": `
+- name: low
+  notify: [pushover, pushovr]
+  when:
+    condition: account.balance("Checking") < 1
+`})
+	results, err := LintWithOptions(dir, time.Now(), LintOptions{PollInterval: time.Minute, Channels: []string{"pushover", "log"}})
+	if err != nil {
+		t.Fatalf("lint error: %v", err)
+	}
+	if results[0].Errors() != 0 || !hasDiagnostic(results[0], `notify channel "pushovr" is not configured (pushover|log); did you mean "pushover"?`) {
+		t.Fatalf("expected unknown channel warning, got %+v", results[0].Diagnostics)
+	}
+}
@@ -0,0 +1,419 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadDir reads all YAML rule files under dir (recursively, following
+// include entries) and compiles their expressions. It fails on the first
+// unknown key, duplicate rule name or expression that does not compile.
+func LoadDir(dir string) ([]Rule, error) {
+	return LoadDirWithCache(dir, nil)
+}
+
+// LoadDirWithCache is LoadDir reusing the programs of earlier loads kept in
+// cache, which is pruned to the programs of this load.
+func LoadDirWithCache(dir string, cache *ProgramCache) ([]Rule, error) {
+	rules, err := loadCompiled(dir, cache)
+	if cache != nil {
+		cache.endLoad(err == nil)
+	}
+	return rules, err
+}
+
+func loadCompiled(dir string, cache *ProgramCache) ([]Rule, error) {
+	rules, scopes, err := loadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		if keys := r.unknownKeys(); len(keys) > 0 {
+			return nil, fmt.Errorf("%srule %s: %s", posPrefix(keys[0].Pos), r.Name, keys[0])
+		}
+	}
+	seen := map[string]Position{}
+	for _, r := range rules {
+		if first, ok := seen[r.Name]; ok && r.Name != "" {
+			return nil, fmt.Errorf("%srule %s: duplicate rule name, first defined at %s", posPrefix(r.Pos), r.Name, first)
+		}
+		seen[r.Name] = r.Pos
+	}
+	if errs := checkDefs(scopes); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	if errs := compileRules(rules, cache); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return rules, nil
+}
+
+// checkDefs compiles the defs of every scope, returning a DefError for each
+// one that fails.
+func checkDefs(scopes []*scope) []error {
+	var errs []error
+	for _, sc := range scopes {
+		errs = append(errs, sc.check()...)
+	}
+	return errs
+}
+
+// loadDir reads the rules under dir without validating or compiling them,
+// along with the global scope and the scope of each file. Files are read in
+// lexical order, subdirectories included; directories whose name starts with
+// a dot are skipped.
+func loadDir(dir string) ([]Rule, []*scope, error) {
+	l := &loader{root: dir, seen: map[string]bool{}, templates: map[string]ruleTemplate{}, global: newScope(nil)}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isRuleFile(path) {
+			return nil
+		}
+		return l.loadFile(path)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := l.expand(); err != nil {
+		return nil, nil, err
+	}
+	if len(l.rules) == 0 {
+		return nil, nil, errors.New("no rule files found")
+	}
+	return l.rules, append([]*scope{l.global}, l.scopes...), nil
+}
+
+// parseError is a rule file, or the node in it, that does not decode.
+type parseError struct {
+	Pos Position
+	Err error
+}
+
+func (e *parseError) Error() string {
+	return fmt.Sprintf("parsing %s: %v", e.Pos, e.Err)
+}
+
+func (e *parseError) Unwrap() error {
+	return e.Err
+}
+
+// nodePos returns where n is in the rule file named file.
+func nodePos(file string, n *yaml.Node) Position {
+	return Position{File: file, Line: n.Line, Column: n.Column}
+}
+
+func isRuleFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// loader collects rules from files, loading each file once even when it is
+// both in the directory and included.
+type loader struct {
+	root      string
+	seen      map[string]bool // absolute paths already loaded
+	rules     []Rule
+	templates map[string]ruleTemplate
+	global    *scope              // constants and defs shared by all files
+	scopes    []*scope            // one per file, children of global
+	instances []*templateInstance // expanded into rules once all files are read
+}
+
+// label names path relative to the rules directory for positions.
+func (l *loader) label(path string) string {
+	rel, err := filepath.Rel(l.root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (l *loader) loadFile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if l.seen[abs] {
+		return nil
+	}
+	l.seen[abs] = true
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	name := l.label(path)
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return &parseError{Pos: Position{File: name}, Err: err}
+	}
+	if len(doc.Content) == 0 {
+		return nil // empty file
+	}
+	sc := newScope(l.global)
+	l.scopes = append(l.scopes, sc)
+	list := doc.Content[0]
+	if list.Kind == yaml.MappingNode {
+		if list, err = l.sections(name, sc, list); err != nil || list == nil {
+			return err
+		}
+	}
+	if list.Kind != yaml.SequenceNode {
+		return &parseError{Pos: nodePos(name, list), Err: errors.New("rule file must be a list of rules")}
+	}
+	for _, n := range list.Content {
+		if patterns, ok, err := includeEntry(n); ok || err != nil {
+			if err != nil {
+				return &parseError{Pos: nodePos(name, n), Err: err}
+			}
+			if err := l.include(path, patterns); err != nil {
+				return fmt.Errorf("%s: %w", nodePos(name, n), err)
+			}
+			continue
+		}
+		if inst, ok, err := instanceEntry(n); ok || err != nil {
+			if err != nil {
+				return &parseError{Pos: nodePos(name, n), Err: err}
+			}
+			inst.pos.File = name
+			inst.index = len(l.rules)
+			l.instances = append(l.instances, inst)
+			l.rules = append(l.rules, Rule{})
+			continue
+		}
+		var r Rule
+		if err := n.Decode(&r); err != nil {
+			return &parseError{Pos: nodePos(name, n), Err: err}
+		}
+		r.setFile(name)
+		r.scope = sc
+		l.rules = append(l.rules, r)
+	}
+	return nil
+}
+
+// sections reads a rule file written as a mapping, which holds templates,
+// constants and defs next to its rules list. Constants and defs go to the
+// file's scope sc, or to the global scope under a global section. It returns
+// the rules list, or nil when there is none.
+func (l *loader) sections(name string, sc *scope, n *yaml.Node) (*yaml.Node, error) {
+	var list *yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		var err error
+		switch key.Value {
+		case "templates":
+			err = l.addTemplates(name, sc, value)
+		case "rules":
+			list = value
+		case "global":
+			err = scopeSections(name, l.global, value)
+		default:
+			err = scopeSection(name, sc, key, value, fileSections)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// fileSections are the keys of a rule file written as a mapping.
+var fileSections = []string{"templates", "rules", "global", "vars", "constants", "defs"}
+
+// scopeSections adds the constants and defs of a global section to sc.
+func scopeSections(name string, sc *scope, n *yaml.Node) error {
+	if n.Kind != yaml.MappingNode {
+		return &parseError{Pos: nodePos(name, n), Err: errors.New("global must be a mapping of vars, constants and defs")}
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if err := scopeSection(name, sc, n.Content[i], n.Content[i+1], scopeKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var scopeKeys = []string{"vars", "constants", "defs"}
+
+// scopeSection adds a vars, constants or defs section to sc. Other keys are
+// reported as unknown, suggesting the closest of known.
+func scopeSection(name string, sc *scope, key, value *yaml.Node, known []string) error {
+	switch key.Value {
+	case "vars", "constants":
+		return sc.addConsts(name, value)
+	case "defs":
+		return sc.addDefs(name, value)
+	}
+	k := unknownKey{Key: key.Value, Pos: nodePos(name, key)}
+	if match, ok := closestName(key.Value, known); ok {
+		k.Suggest = match
+	}
+	return &parseError{Pos: k.Pos, Err: errors.New(k.String())}
+}
+
+// include loads the files matching patterns, which are relative to the
+// including file's directory and may contain globs.
+func (l *loader) include(from string, patterns []string) error {
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(from), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("include %q: %w", pattern, err)
+		}
+		var files []string
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && !info.IsDir() {
+				files = append(files, m)
+			}
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("include %q matches no files", pattern)
+		}
+		sort.Strings(files)
+		for _, f := range files {
+			if err := l.loadFile(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// includeEntry reports whether n is an include entry ("include: path" or a
+// list of paths) rather than a rule.
+func includeEntry(n *yaml.Node) ([]string, bool, error) {
+	if n.Kind != yaml.MappingNode {
+		return nil, false, nil
+	}
+	value := mappingValue(n, "include")
+	if value == nil {
+		return nil, false, nil
+	}
+	if len(n.Content) != 2 {
+		return nil, true, errors.New("include entries take no other keys")
+	}
+	var patterns []string
+	if value.Kind == yaml.ScalarNode {
+		patterns = []string{value.Value}
+	} else if err := value.Decode(&patterns); err != nil {
+		return nil, true, fmt.Errorf("include must be a path or a list of paths")
+	}
+	return patterns, true, nil
+}
+
+// unknownKey is a mapping key that matches no field of the type it decodes
+// into, usually a typo.
+type unknownKey struct {
+	Key     string
+	Suggest string
+	Pos     Position
+}
+
+func (k unknownKey) String() string {
+	if k.Suggest != "" {
+		return fmt.Sprintf("unknown field %q (did you mean %q?)", k.Key, k.Suggest)
+	}
+	return fmt.Sprintf("unknown field %q", k.Key)
+}
+
+// unknownKeys returns the keys of mapping node n that v's yaml fields do not
+// declare.
+func unknownKeys(n *yaml.Node, v interface{}) []unknownKey {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	known := yamlFields(reflect.TypeOf(v))
+	var out []unknownKey
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key := n.Content[i]
+		if contains(known, key.Value) {
+			continue
+		}
+		k := unknownKey{Key: key.Value, Pos: Position{Line: key.Line, Column: key.Column}}
+		if match, ok := closestName(key.Value, known); ok {
+			k.Suggest = match
+		}
+		out = append(out, k)
+	}
+	return out
+}
+
+// yamlFields lists the yaml keys of struct type t.
+func yamlFields(t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = strings.ToLower(f.Name)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// mappingValue returns the value node of key in mapping n, if present.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// unknownKeys returns every unknown key in the rule, its pushover block and
+// its when and observe entries.
+func (r Rule) unknownKeys() []unknownKey {
+	out := append([]unknownKey(nil), r.unknown...)
+	for _, obs := range r.Observe {
+		out = append(out, obs.unknown...)
+	}
+	for _, w := range r.When {
+		out = append(out, w.unknown...)
+	}
+	return out
+}
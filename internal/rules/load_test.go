@@ -0,0 +1,156 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFiles creates files (slash-separated paths relative to dir) with the
+// given contents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+	}
+}
+
+func ruleNames(rs []Rule) []string {
+	var names []string
+	for _, r := range rs {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestLoadDirRecursive(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base.yaml": `
+- name: base
+  when:
+    condition: account.balance("Checking") < 1
+`,
+		"cards/visa.yaml": `
+- name: visa
+  when:
+    condition: account.balance("Visa") < 1
+`,
+		".git/ignored.yaml": `
+- name: ignored
+  when:
+    condition: true
+`,
+	})
+	rs, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if got := strings.Join(ruleNames(rs), ","); got != "base,visa" {
+		t.Fatalf("expected base,visa, got %s", got)
+	}
+	if got := rs[1].Pos.String(); got != "cards/visa.yaml:2:3" {
+		t.Fatalf("expected nested file position, got %s", got)
+	}
+}
+
+func TestLoadDirInclude(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "rules")
+	writeFiles(t, root, map[string]string{
+		"rules/main.yaml": `
+- include: ../shared/*.yaml
+- include: [extra/one.yaml]
+- name: main
+  when:
+    condition: true
+`,
+		"rules/extra/one.yaml": `
+- name: one
+  when:
+    condition: true
+`,
+		"shared/a.yaml": `
+- name: shared_a
+  when:
+    condition: true
+`,
+		"shared/b.yaml": `
+- name: shared_b
+  when:
+    condition: true
+`,
+	})
+	rs, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	// extra/one.yaml is both in the directory and included; it loads once
+	if got := strings.Join(ruleNames(rs), ","); got != "one,shared_a,shared_b,main" {
+		t.Fatalf("unexpected rules %s", got)
+	}
+	if got := rs[1].Pos.File; got != "../shared/a.yaml" {
+		t.Fatalf("expected included file label, got %s", got)
+	}
+
+	writeFiles(t, root, map[string]string{"rules/main.yaml": "- include: missing/*.yaml\n"})
+	if _, err := LoadDir(dir); err == nil || !strings.Contains(err.Error(), "main.yaml:1:3") || !strings.Contains(err.Error(), "matches no files") {
+		t.Fatalf("expected include error with position, got %v", err)
+	}
+}
+
+func TestLoadDirUnknownFields(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"r.yaml": `
+- name: typo
+  when:
+    condtion: account.balance("Checking") < 1
+`})
+	_, err := LoadDir(dir)
+	if err == nil {
+		t.Fatalf("expected unknown field error")
+	}
+	want := `r.yaml:4:5: rule typo: unknown field "condtion" (did you mean "condition"?)`
+	if err.Error() != want {
+		t.Fatalf("expected %q, got %q", want, err)
+	}
+
+	results, err := Lint(dir, time.Now())
+	if err != nil {
+		t.Fatalf("lint error: %v", err)
+	}
+	if len(results) != 1 || !hasDiagnostic(results[0], `unknown field "condtion"`) {
+		t.Fatalf("expected lint to report unknown field, got %+v", results)
+	}
+}
+
+func TestLoadDirDuplicateNames(t *testing.T) {
+	dir := t.TempDir()
+	rule := `
+- name: same
+  when:
+    condition: true
+`
+	writeFiles(t, dir, map[string]string{"a.yaml": rule, "b/c.yaml": rule})
+	_, err := LoadDir(dir)
+	if err == nil || err.Error() != "b/c.yaml:2:3: rule same: duplicate rule name, first defined at a.yaml:2:3" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func hasDiagnostic(r LintResult, substr string) bool {
+	for _, d := range r.Diagnostics {
+		if strings.Contains(d.Message, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,40 +1,245 @@
 package rules
 
 import (
-	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/expr-lang/expr/vm"
 	"gopkg.in/yaml.v3"
+
+	"ynab-alerts/internal/daytime"
 )
 
 // Rule represents a rule definition loaded from YAML.
 type Rule struct {
-	Name    string      `yaml:"name"`
-	Observe ObserveList `yaml:"observe,omitempty"`
-	When    WhenList    `yaml:"when"`
-	Notify  []string    `yaml:"notify"`
-	Meta    interface{} `yaml:"meta,omitempty"`
+	Name       string           `yaml:"name"`
+	Observe    ObserveList      `yaml:"observe,omitempty"`
+	When       WhenList         `yaml:"when"`
+	Notify     []string         `yaml:"notify"`
+	Delivery   string           `yaml:"delivery,omitempty"`    // "immediate" (default) or "digest"
+	Severity   string           `yaml:"severity,omitempty"`    // info, warning (default) or critical
+	QuietHours string           `yaml:"quiet_hours,omitempty"` // defer deliveries during HH:MM-HH:MM
+	Escalation string           `yaml:"escalation,omitempty"`  // escalation policy name from config
+	Timezone   string           `yaml:"timezone,omitempty"`    // IANA zone overriding the configured one
+	Pushover   *PushoverOptions `yaml:"pushover,omitempty"`
+	Meta       interface{}      `yaml:"meta,omitempty"`
+	Pos        Position         `yaml:"-"` // where the rule is defined, set by LoadDir
+
+	unknown []unknownKey
+	scope   *scope         // constants and defs of the rule's file
+	loc     *time.Location // resolved Timezone, set by Compile
+}
+
+// UnmarshalYAML decodes a rule and remembers where it is defined.
+func (r *Rule) UnmarshalYAML(value *yaml.Node) error {
+	type plain Rule
+	if err := value.Decode((*plain)(r)); err != nil {
+		return err
+	}
+	r.Pos = Position{Line: value.Line, Column: value.Column}
+	r.unknown = unknownKeys(value, Rule{})
+	if p := mappingValue(value, "pushover"); p != nil {
+		r.unknown = append(r.unknown, unknownKeys(p, PushoverOptions{})...)
+	}
+	return nil
+}
+
+// Delivery modes for a rule's alerts.
+const (
+	DeliveryImmediate = "immediate"
+	DeliveryDigest    = "digest"
+)
+
+// Severity levels for rules.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Level returns the rule's normalized severity, defaulting to warning.
+func (r Rule) Level() string {
+	switch sev := strings.ToLower(strings.TrimSpace(r.Severity)); sev {
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+		return sev
+	default:
+		return SeverityWarning
+	}
+}
+
+// In converts t to the rule's own timezone, if it sets one. Rules from
+// LoadDir resolve the zone once; others look it up here, and an invalid zone
+// leaves t unchanged.
+func (r Rule) In(t time.Time) time.Time {
+	if r.loc != nil {
+		return t.In(r.loc)
+	}
+	if err := r.resolveTimezone(); err != nil || r.loc == nil {
+		return t
+	}
+	return t.In(r.loc)
+}
+
+// resolveTimezone loads the rule's Timezone into loc.
+func (r *Rule) resolveTimezone() error {
+	tz := strings.TrimSpace(r.Timezone)
+	if tz == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return fmt.Errorf("timezone %q is invalid", r.Timezone)
+	}
+	r.loc = loc
+	return nil
+}
+
+// InQuietHours reports whether deliveries for the rule should wait at t.
+// Invalid windows are reported by lint and treated as not quiet.
+func (r Rule) InQuietHours(t time.Time) bool {
+	if strings.TrimSpace(r.QuietHours) == "" {
+		return false
+	}
+	q, err := daytime.ParseRange(r.QuietHours)
+	if err != nil {
+		return false
+	}
+	return q.Contains(r.In(t))
+}
+
+// IsDigest reports whether the rule's alerts are batched into the digest.
+func (r Rule) IsDigest() bool {
+	return strings.EqualFold(strings.TrimSpace(r.Delivery), DeliveryDigest)
+}
+
+var accountRefPattern = regexp.MustCompile(`account\.(?:balance|due)\(\s*"([^"]+)"\s*\)`)
+
+// AccountRefs returns the account names referenced by the rule's conditions,
+// clear conditions and observation values, including through defs, in
+// first-seen order.
+func (r Rule) AccountRefs() []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, e := range r.expressions() {
+		for _, m := range accountRefPattern.FindAllStringSubmatch(r.scope.expand(e), -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				out = append(out, m[1])
+			}
+		}
+	}
+	return out
+}
+
+// expressions returns the observation values, conditions and clear
+// conditions of the rule.
+func (r Rule) expressions() []string {
+	var exprs []string
+	for _, obs := range r.Observe {
+		exprs = append(exprs, obs.Value)
+	}
+	for _, w := range r.When {
+		exprs = append(exprs, w.Condition, w.ClearCondition)
+	}
+	return exprs
+}
+
+// PushoverOptions tunes how a rule's alerts are delivered through Pushover.
+type PushoverOptions struct {
+	Priority *int     `yaml:"priority,omitempty"`  // -2 (lowest) .. 2 (emergency)
+	Retry    Duration `yaml:"retry,omitempty"`     // emergency: re-notify interval (>= 30s)
+	Expire   Duration `yaml:"expire,omitempty"`    // emergency: stop re-notifying after (<= 3h)
+	Sound    string   `yaml:"sound,omitempty"`     // Pushover sound name
+	URL      string   `yaml:"url,omitempty"`       // supplementary link, e.g. the YNAB budget
+	URLTitle string   `yaml:"url_title,omitempty"` // label for URL
+	HTML     bool     `yaml:"html,omitempty"`      // message contains HTML
+	TTL      Duration `yaml:"ttl,omitempty"`       // auto-delete from devices after
+}
+
+// Duration is a time.Duration read from YAML strings such as "90s" or "1h".
+type Duration time.Duration
+
+// UnmarshalYAML parses a Go duration string.
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var raw string
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	dur, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", value.Line, raw)
+	}
+	*d = Duration(dur)
+	return nil
 }
 
 // Observe captures a value under a named variable on a schedule.
 type Observe struct {
-	CaptureOn string `yaml:"capture_on"` // day-of-month (1-31) or empty for every run
-	Variable  string `yaml:"variable"`
-	Value     string `yaml:"value"` // expression to evaluate to a money value
+	CaptureOn      string   `yaml:"capture_on"`                 // day gate or cron schedule; empty/"always" for every run
+	Shift          string   `yaml:"shift,omitempty"`            // move a day-of-month capture off non-business days
+	CaptureOncePer string   `yaml:"capture_once_per,omitempty"` // day, week or month; empty = no limit beyond capture_on
+	CatchUp        bool     `yaml:"catch_up,omitempty"`         // capture late if the capture day was missed
+	Variable       string   `yaml:"variable"`
+	Value          string   `yaml:"value"` // expression to evaluate to a money value
+	Pos            Position `yaml:"-"`
+
+	value    *vm.Program // compiled Value, set by Compile
+	valuePos Position
+	unknown  []unknownKey
+}
+
+// UnmarshalYAML decodes an observation and remembers where its value
+// expression is.
+func (o *Observe) UnmarshalYAML(value *yaml.Node) error {
+	type plain Observe
+	if err := value.Decode((*plain)(o)); err != nil {
+		return err
+	}
+	o.Pos = Position{Line: value.Line, Column: value.Column}
+	o.valuePos = valuePositions(value)["value"]
+	o.unknown = unknownKeys(value, Observe{})
+	return nil
 }
 
 // When describes the evaluation condition for a rule.
 type When struct {
-	Window           string   `yaml:"window,omitempty"`              // optional textual window; best-effort
-	DayOfMonth       []int    `yaml:"day_of_month,omitempty"`        // restrict evaluation to these days (1-31)
-	DayOfMonthRanges []string `yaml:"day_of_month_range,omitempty"`  // e.g., ["27-5"] to span across months
-	DaysOfWeek       []string `yaml:"days_of_week,omitempty"`        // restrict to weekdays (Mon-Sun)
-	NthWeekday       string   `yaml:"nth_weekday,omitempty"`         // e.g., "1 Monday", "last Friday"
-	Schedule         string   `yaml:"schedule,omitempty"`            // cron-like "min hour dom mon dow"
-	Condition        string   `yaml:"condition,omitempty"`           // expression returning bool
+	Window             string   `yaml:"window,omitempty"`                // time of day, e.g. "08:00-10:00" or "Mon-Fri 09:00-17:00"
+	DayOfMonth         []int    `yaml:"day_of_month,omitempty"`          // restrict evaluation to these days (1-31)
+	DayOfMonthRanges   []string `yaml:"day_of_month_range,omitempty"`    // e.g., ["27-5"] to span across months
+	DaysOfWeek         []string `yaml:"days_of_week,omitempty"`          // restrict to weekdays (Mon-Sun)
+	NthWeekday         string   `yaml:"nth_weekday,omitempty"`           // e.g., "1 Monday", "last Friday"
+	BusinessDayOfMonth []int    `yaml:"business_day_of_month,omitempty"` // e.g., [1, -1] = first and last business day
+	Shift              string   `yaml:"shift,omitempty"`                 // move day_of_month off non-business days
+	Schedule           string   `yaml:"schedule,omitempty"`              // cron-like "min hour dom mon dow"
+	Condition          string   `yaml:"condition,omitempty"`             // expression returning bool
+	For                Duration `yaml:"for,omitempty"`                   // condition must hold this long before firing
+	ClearCondition     string   `yaml:"clear_condition,omitempty"`       // once firing, stay firing until this is true
+	Pos                Position `yaml:"-"`
+
+	condition, clear       *vm.Program // compiled expressions, set by Compile
+	conditionPos, clearPos Position
+	unknown                []unknownKey
+}
+
+// UnmarshalYAML decodes a when clause and remembers where its expressions
+// are.
+func (w *When) UnmarshalYAML(value *yaml.Node) error {
+	type plain When
+	if err := value.Decode((*plain)(w)); err != nil {
+		return err
+	}
+	w.Pos = Position{Line: value.Line, Column: value.Column}
+	pos := valuePositions(value)
+	w.conditionPos, w.clearPos = pos["condition"], pos["clear_condition"]
+	w.unknown = unknownKeys(value, When{})
+	return nil
+}
+
+// stateful reports whether the clause needs state kept across evaluations.
+func (w When) stateful() bool {
+	return w.For > 0 || strings.TrimSpace(w.ClearCondition) != ""
 }
 
 // ObserveList allows single-object or list YAML.
@@ -75,48 +280,30 @@ func (w *WhenList) UnmarshalYAML(unmarshal func(interface{}) error) error {
 
 // Data is the evaluation context.
 type Data struct {
-	Accounts map[string]int64
-	Vars     map[string]int64
-	Now      time.Time
+	Accounts    map[string]int64
+	AccountInfo map[string]AccountInfo // types for the accounts.* filters (optional)
+	Vars        map[string]int64
+	Now         time.Time
+	Calendar    *Calendar // holidays for business-day gates (optional)
 }
 
 // Trigger represents a fired rule.
 type Trigger struct {
-	Rule    Rule
-	Message string
+	Rule     Rule
+	When     When // the clause that fired
+	Message  string
+	Severity string
 }
 
-// LoadDir reads all YAML files in the directory into a rule slice.
-func LoadDir(dir string) ([]Rule, error) {
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, err
-	}
-
-	var rules []Rule
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		switch filepath.Ext(entry.Name()) {
-		case ".yaml", ".yml":
-		default:
-			continue
-		}
+// Pos returns where the clause that fired is defined, or the rule when the
+// clause has no position.
+func (t Trigger) Pos() Position {
+	return orPos(t.When.Pos, t.Rule.Pos)
+}
 
-		path := filepath.Join(dir, entry.Name())
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return nil, err
-		}
-		var fileRules []Rule
-		if err := yaml.Unmarshal(content, &fileRules); err != nil {
-			return nil, fmt.Errorf("parsing %s: %w", entry.Name(), err)
-		}
-		rules = append(rules, fileRules...)
-	}
-	if len(rules) == 0 {
-		return nil, errors.New("no rule files found")
-	}
-	return rules, nil
+// Result is the outcome of evaluating a set of rules.
+type Result struct {
+	Triggers []Trigger
+	Checked  []string // names of rules with at least one condition evaluated
+	Silenced []string // names of rules skipped because they are snoozed or muted
 }
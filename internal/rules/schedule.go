@@ -0,0 +1,117 @@
+package rules
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// lastActivation returns the latest activation of sched at or before now,
+// looking back up to a year.
+func lastActivation(sched cron.Schedule, now time.Time) (time.Time, bool) {
+	for _, lookback := range []time.Duration{time.Hour, 24 * time.Hour, 8 * 24 * time.Hour, 32 * 24 * time.Hour, 367 * 24 * time.Hour} {
+		next := sched.Next(now.Add(-lookback))
+		if next.After(now) {
+			continue
+		}
+		for {
+			after := sched.Next(next)
+			if after.After(now) {
+				return next, true
+			}
+			next = after
+		}
+	}
+	return time.Time{}, false
+}
+
+// Scheduled reports whether any of the rule's when clauses has a cron schedule.
+func (r Rule) Scheduled() bool {
+	for _, w := range r.When {
+		if w.Schedule != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduledClauses returns a copy of the rule keeping only its when clauses
+// with a cron schedule, for evaluating a scheduled wake-up without the
+// clauses that only run on polls.
+func (r Rule) ScheduledClauses() Rule {
+	var when WhenList
+	for _, w := range r.When {
+		if w.Schedule != "" {
+			when = append(when, w)
+		}
+	}
+	r.When = when
+	return r
+}
+
+// NextRun returns the earliest cron activation after now across the rules'
+// when schedules, each read in its rule's timezone. Invalid schedules are
+// reported by lint and ignored.
+func NextRun(rules []Rule, now time.Time) (time.Time, bool) {
+	var best time.Time
+	for _, r := range rules {
+		for _, w := range r.When {
+			if w.Schedule == "" {
+				continue
+			}
+			sched, err := cron.ParseStandard(w.Schedule)
+			if err != nil {
+				continue
+			}
+			next := sched.Next(r.In(now))
+			if best.IsZero() || next.Before(best) {
+				best = next
+			}
+		}
+	}
+	return best, !best.IsZero()
+}
+
+// scheduleDue reports whether a scheduled clause has an activation at or
+// before now that has not been evaluated yet, returning that activation for
+// markScheduled once the clause has been evaluated. Instants missed while the
+// daemon was down collapse into one catch-up evaluation. A clause seen for
+// the first time only runs when now falls in its activation minute, so
+// history is not replayed.
+func scheduleDue(store *Store, key string, when When, now time.Time) (bool, time.Time, error) {
+	sched, err := cron.ParseStandard(when.Schedule)
+	if err != nil {
+		dbg.Debugf("clause %s skipping invalid schedule %q: %v", key, when.Schedule, err)
+		return false, time.Time{}, nil
+	}
+	occ, ok := lastActivation(sched, now)
+	if !ok {
+		return false, time.Time{}, nil
+	}
+	st := store.Clause(key)
+	var due bool
+	switch {
+	case st.ScheduledAt.IsZero():
+		due = sameMinute(occ, now)
+	case st.ScheduledAt.Before(occ):
+		due = true
+		if missed := sched.Next(st.ScheduledAt); missed.Before(occ) {
+			dbg.Debugf("clause %s catching up schedule missed since %s", key, missed.Format(time.RFC3339))
+		}
+	}
+	if due {
+		return true, occ, nil
+	}
+	// nothing to evaluate: remember the activation so it does not run later
+	return false, occ, markScheduled(store, key, occ)
+}
+
+// markScheduled records activation occ of a clause as evaluated.
+func markScheduled(store *Store, key string, occ time.Time) error {
+	st := store.Clause(key)
+	if st.ScheduledAt.Equal(occ) {
+		return nil
+	}
+	st.ScheduledAt = occ
+	return store.SetClause(key, st)
+}
@@ -0,0 +1,116 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEvaluateScheduleRunsOncePerActivation(t *testing.T) {
+	store, err := NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	r := Rule{
+		Name: "mid_month",
+		When: WhenList{{Schedule: "0 9 14 * *", Condition: `account.balance("Checking") < 100`}},
+	}
+	fires := func(now time.Time) bool {
+		t.Helper()
+		data := Data{Accounts: map[string]int64{"Checking": 50_000}, Vars: map[string]int64{}, Now: now}
+		trigs, err := Evaluate(context.Background(), []Rule{r}, store, data)
+		if err != nil {
+			t.Fatalf("evaluate error: %v", err)
+		}
+		return len(trigs) == 1
+	}
+
+	// first sight outside the activation minute does not replay history
+	if fires(time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected no run before the first activation")
+	}
+	if !fires(time.Date(2024, time.March, 14, 9, 0, 5, 0, time.UTC)) {
+		t.Fatalf("expected run at the activation")
+	}
+	if fires(time.Date(2024, time.March, 14, 9, 0, 40, 0, time.UTC)) {
+		t.Fatalf("expected a single run per activation")
+	}
+	// down from April 13 until May 20: both missed activations collapse into one run
+	if !fires(time.Date(2024, time.May, 20, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected catch-up run after downtime")
+	}
+	if fires(time.Date(2024, time.May, 21, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected catch-up to run once")
+	}
+}
+
+func TestEvaluateScheduleRetriesFailedActivation(t *testing.T) {
+	store, err := NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	r := Rule{
+		Name: "nine",
+		When: WhenList{{Schedule: "0 9 * * *", Condition: `account.balance("Checking") < 100`}},
+	}
+	at := time.Date(2024, time.March, 14, 9, 0, 5, 0, time.UTC)
+	if _, err := Evaluate(context.Background(), []Rule{r}, store, Data{Accounts: map[string]int64{"Checking": 500_000}, Now: at.AddDate(0, 0, -1)}); err != nil {
+		t.Fatalf("evaluate error: %v", err)
+	}
+	// the account is missing from this poll, so evaluation fails
+	if _, err := Evaluate(context.Background(), []Rule{r}, store, Data{Accounts: map[string]int64{}, Now: at}); err == nil {
+		t.Fatalf("expected evaluation error")
+	}
+	data := Data{Accounts: map[string]int64{"Checking": 50_000}, Now: at.Add(time.Minute)}
+	trigs, err := Evaluate(context.Background(), []Rule{r}, store, data)
+	if err != nil || len(trigs) != 1 {
+		t.Fatalf("expected the failed activation to run again, got %v (%v)", trigs, err)
+	}
+}
+
+func TestScheduledClausesDropsPollClauses(t *testing.T) {
+	store, err := NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	r := Rule{
+		Name: "mixed",
+		When: WhenList{
+			{Condition: `account.balance("Checking") < 100`},
+			{Schedule: "0 9 * * *", Condition: `account.balance("Savings") < 100`},
+		},
+	}
+	scheduled := r.ScheduledClauses()
+	if len(scheduled.When) != 1 || scheduled.When[0].Schedule == "" || len(r.When) != 2 {
+		t.Fatalf("expected only the scheduled clause in a copy, got %+v (original %+v)", scheduled.When, r.When)
+	}
+	data := Data{
+		Accounts: map[string]int64{"Checking": 50_000, "Savings": 500_000},
+		Vars:     map[string]int64{},
+		Now:      time.Date(2024, time.March, 14, 9, 0, 5, 0, time.UTC),
+	}
+	trigs, err := Evaluate(context.Background(), []Rule{scheduled}, store, data)
+	if err != nil {
+		t.Fatalf("evaluate error: %v", err)
+	}
+	if len(trigs) != 0 {
+		t.Fatalf("expected the poll clause to be skipped on a scheduled run, got %+v", trigs)
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	rs := []Rule{
+		{Name: "polled", When: WhenList{{Condition: "true"}}},
+		{Name: "daily", When: WhenList{{Schedule: "0 9 * * *", Condition: "true"}}},
+		{Name: "chicago", Timezone: "America/Chicago", When: WhenList{{Schedule: "0 8 * * *", Condition: "true"}}},
+	}
+	now := time.Date(2024, time.March, 14, 10, 0, 0, 0, time.UTC)
+	next, ok := NextRun(rs, now)
+	// 08:00 in Chicago (CDT) is 13:00 UTC, before tomorrow's 09:00 UTC
+	if want := time.Date(2024, time.March, 14, 13, 0, 0, 0, time.UTC); !ok || !next.Equal(want) {
+		t.Fatalf("expected %s, got %s (ok=%v)", want, next, ok)
+	}
+	if _, ok := NextRun(rs[:1], now); ok {
+		t.Fatalf("expected no activation without schedules")
+	}
+}
@@ -0,0 +1,296 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/file"
+	"github.com/expr-lang/expr/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// scope holds the constants and named expressions (defs) rule expressions
+// reference as const.<name> and def.<name>. Each rule file has its own scope
+// whose parent is the global scope shared by all files; a file's names shadow
+// global ones. Both are inlined when expressions compile, so they never mix
+// with the observed var.<name> values in the store.
+type scope struct {
+	consts map[string]interface{} // float64, string or bool
+	defs   map[string]string
+	pos    map[string]Position // where each name is defined
+	parent *scope
+
+	checked map[string]error // def compile results, see checkDef
+	key     string           // fingerprint for the program cache, see cacheKey
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{
+		consts:  map[string]interface{}{},
+		defs:    map[string]string{},
+		pos:     map[string]Position{},
+		parent:  parent,
+		checked: map[string]error{},
+	}
+}
+
+// empty reports whether no names are visible from s.
+func (s *scope) empty() bool {
+	return s == nil || (len(s.consts) == 0 && len(s.defs) == 0 && s.parent.empty())
+}
+
+// constant resolves const.<name>.
+func (s *scope) constant(name string) (interface{}, bool) {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.consts[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// def resolves def.<name> and the scope it is defined in, which its own
+// references resolve against.
+func (s *scope) def(name string) (string, *scope, bool) {
+	for ; s != nil; s = s.parent {
+		if src, ok := s.defs[name]; ok {
+			return src, s, true
+		}
+	}
+	return "", nil, false
+}
+
+// addConsts records the constants of mapping n, defined in file.
+func (s *scope) addConsts(file string, n *yaml.Node) error {
+	return s.addEach("const", file, n, func(name string, value *yaml.Node) error {
+		var v interface{}
+		if value.Kind == yaml.ScalarNode {
+			if err := value.Decode(&v); err != nil {
+				return err
+			}
+		}
+		switch c := v.(type) {
+		case int:
+			v = float64(c)
+		case float64, string, bool:
+		default:
+			return fmt.Errorf("constant %s must be a number, string or boolean", name)
+		}
+		s.consts[name] = v
+		return nil
+	})
+}
+
+// addDefs records the named expressions of mapping n, defined in file.
+func (s *scope) addDefs(file string, n *yaml.Node) error {
+	return s.addEach("def", file, n, func(name string, value *yaml.Node) error {
+		if value.Kind != yaml.ScalarNode || strings.TrimSpace(value.Value) == "" {
+			return fmt.Errorf("def %s must be an expression", name)
+		}
+		s.defs[name] = value.Value
+		return nil
+	})
+}
+
+// addEach adds every entry of mapping n as kind.<name>, recording where its
+// value is defined.
+func (s *scope) addEach(kind, file string, n *yaml.Node, add func(name string, value *yaml.Node) error) error {
+	if n.Kind != yaml.MappingNode {
+		return &parseError{Pos: nodePos(file, n), Err: fmt.Errorf("%s must map names to values", kind)}
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		ref := kind + "." + key.Value
+		if !identPattern.MatchString(key.Value) {
+			return &parseError{Pos: nodePos(file, key), Err: fmt.Errorf("name %q is not an identifier", key.Value)}
+		}
+		if first, ok := s.pos[ref]; ok {
+			return &parseError{Pos: nodePos(file, key), Err: fmt.Errorf("%s is already defined at %s", ref, first)}
+		}
+		if err := add(key.Value, value); err != nil {
+			return &parseError{Pos: nodePos(file, value), Err: err}
+		}
+		pos := scalarPosition(value)
+		pos.File = file
+		s.pos[ref] = pos
+	}
+	return nil
+}
+
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// cacheKey fingerprints every name visible from s, level by level from s up
+// to the global scope, so programs compiled in different scopes do not share
+// a cache entry while an unchanged scope reuses its programs across reloads.
+// Keeping the levels apart matters: the same names split differently between
+// a file and the global scope shadow differently.
+func (s *scope) cacheKey() string {
+	if s.empty() {
+		return ""
+	}
+	if s.key == "" {
+		var levels []string
+		for p := s; p != nil; p = p.parent {
+			var parts []string
+			for name, v := range p.consts {
+				parts = append(parts, fmt.Sprintf("const.%s=%#v", name, v))
+			}
+			for name, src := range p.defs {
+				parts = append(parts, fmt.Sprintf("def.%s=%q", name, src))
+			}
+			sort.Strings(parts)
+			levels = append(levels, strings.Join(parts, "\x00"))
+		}
+		s.key = strings.Join(levels, "\x01")
+	}
+	return s.key
+}
+
+// checkDef compiles def name on its own, so a def that does not compile is
+// reported once at its definition rather than at every use.
+func (s *scope) checkDef(name string, stack []string) error {
+	if err, ok := s.checked[name]; ok {
+		return err
+	}
+	_, err := compileExpr(s.defs[name], s, push(stack, name))
+	s.checked[name] = err
+	return err
+}
+
+// check compiles every def defined directly in s.
+func (s *scope) check() []error {
+	names := make([]string, 0, len(s.defs))
+	for name := range s.defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		if err := s.checkDef(name, nil); err != nil {
+			errs = append(errs, &DefError{Name: name, Pos: errorPosition(s.pos["def."+name], s.defs[name], err), Err: err})
+		}
+	}
+	return errs
+}
+
+// DefError is a named expression that does not compile.
+type DefError struct {
+	Name string
+	Pos  Position
+	Err  error
+}
+
+func (e *DefError) Error() string {
+	return fmt.Sprintf("%sdef %s: %s", posPrefix(e.Pos), e.Name, exprMessage(e.Err))
+}
+
+func (e *DefError) Unwrap() error {
+	return e.Err
+}
+
+// scopePatcher replaces const.<name> with the constant's value and
+// def.<name> with the def's expression. Unknown names are recorded in err,
+// which takes precedence over the checker's "unknown name" errors.
+type scopePatcher struct {
+	scope *scope
+	src   string   // the expression being compiled, for error positions
+	stack []string // defs being expanded, to detect cycles
+	err   error
+}
+
+func (p *scopePatcher) Visit(node *ast.Node) {
+	m, ok := (*node).(*ast.MemberNode)
+	if !ok || p.err != nil {
+		return
+	}
+	id, ok := m.Node.(*ast.IdentifierNode)
+	if !ok {
+		return
+	}
+	prop, ok := m.Property.(*ast.StringNode)
+	if !ok {
+		return
+	}
+	switch id.Value {
+	case "const":
+		v, ok := p.scope.constant(prop.Value)
+		if !ok {
+			p.fail(id, "unknown constant const.%s", prop.Value)
+			return
+		}
+		ast.Patch(node, literal(v))
+	case "def":
+		src, owner, ok := p.scope.def(prop.Value)
+		if !ok {
+			p.fail(id, "unknown def def.%s", prop.Value)
+			return
+		}
+		for _, n := range p.stack {
+			if n == prop.Value {
+				p.fail(id, "def.%s refers to itself (%s -> %s)", prop.Value, strings.Join(p.stack, " -> "), prop.Value)
+				return
+			}
+		}
+		if err := owner.checkDef(prop.Value, p.stack); err != nil {
+			p.fail(id, "def.%s does not compile: %s", prop.Value, exprMessage(err))
+			return
+		}
+		rewritten, _ := namedArgs(src)
+		tree, err := parser.Parse(rewritten)
+		if err != nil {
+			p.fail(id, "def.%s does not compile: %s", prop.Value, exprMessage(err))
+			return
+		}
+		inner := &scopePatcher{scope: owner, src: rewritten, stack: push(p.stack, prop.Value)}
+		ast.Walk(&tree.Node, inner)
+		if inner.err != nil {
+			p.err = inner.err
+			return
+		}
+		ast.Patch(node, tree.Node)
+	}
+}
+
+func (p *scopePatcher) fail(n ast.Node, format string, args ...interface{}) {
+	fe := &file.Error{Location: n.Location(), Message: fmt.Sprintf(format, args...)}
+	p.err = fe.Bind(file.NewSource(p.src))
+}
+
+func push(stack []string, name string) []string {
+	return append(append([]string(nil), stack...), name)
+}
+
+func literal(v interface{}) ast.Node {
+	switch c := v.(type) {
+	case float64:
+		return &ast.FloatNode{Value: c}
+	case bool:
+		return &ast.BoolNode{Value: c}
+	default:
+		return &ast.StringNode{Value: fmt.Sprint(c)}
+	}
+}
+
+var defRefPattern = regexp.MustCompile(`\bdef\.([A-Za-z_][A-Za-z0-9_]*)`)
+
+// expand returns src with def references replaced by their expressions, for
+// the checks that scan expression text for var.<name> and account names.
+func (s *scope) expand(src string) string {
+	return s.expandDepth(src, 0)
+}
+
+func (s *scope) expandDepth(src string, depth int) string {
+	if s.empty() || depth > 10 || !strings.Contains(src, "def.") {
+		return src
+	}
+	return defRefPattern.ReplaceAllStringFunc(src, func(ref string) string {
+		def, owner, ok := s.def(strings.TrimPrefix(ref, "def."))
+		if !ok {
+			return ref
+		}
+		return "(" + owner.expandDepth(def, depth+1) + ")"
+	})
+}
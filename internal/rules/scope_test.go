@@ -0,0 +1,151 @@
+package rules
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const scopeGlobal = `
+global:
+  vars:
+    min_buffer: 200
+  defs:
+    spendable: account.balance("Checking") - account.due("CC_Main")
+`
+
+func TestLoadDirConstantsAndDefs(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"global.yaml": scopeGlobal,
+		"a.yaml": `
+- name: global_buffer
+  when:
+    condition: def.spendable < const.min_buffer
+`,
+		"b.yaml": `
+constants:
+  min_buffer: 500
+defs:
+  after_rent: def.spendable - var.rent
+rules:
+  - name: file_buffer
+    when:
+      condition: def.spendable < const.min_buffer
+  - name: after_rent
+    when:
+      condition: def.after_rent < 0
+`,
+	})
+	rs, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if got := strings.Join(rs[2].AccountRefs(), ","); got != "Checking,CC_Main" {
+		t.Fatalf("expected account refs through defs, got %s", got)
+	}
+
+	// spendable = 1000 - 700 = 300: below b.yaml's 500, not below the global 200
+	data := Data{Accounts: map[string]int64{"Checking": 1000000, "CC_Main": 700000}, Now: time.Now()}
+	triggers, err := Evaluate(context.Background(), rs, nil, data)
+	if err != nil {
+		t.Fatalf("evaluate error: %v", err)
+	}
+	if len(triggers) != 1 || triggers[0].Rule.Name != "file_buffer" {
+		t.Fatalf("expected only file_buffer to fire (var.rent missing), got %+v", triggers)
+	}
+
+	data.Vars = map[string]int64{"rent": 400000}
+	triggers, err = Evaluate(context.Background(), rs, nil, data)
+	if err != nil {
+		t.Fatalf("evaluate error: %v", err)
+	}
+	if len(triggers) != 2 || triggers[1].Rule.Name != "after_rent" {
+		t.Fatalf("expected after_rent to fire once var.rent is known, got %+v", triggers)
+	}
+}
+
+func TestLoadDirScopeErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "unknown constant",
+			content: `
+- name: typo
+  when:
+    condition: def.spendable < const.min_bufer
+`,
+			want: "r.yaml:4:32: rule typo: condition: unknown constant const.min_bufer",
+		},
+		{
+			name: "invalid def",
+			content: `
+defs:
+  bad: account.balance("Checking") + true
+rules:
+  - name: ok
+    when:
+      condition: "true"
+`,
+			want: "r.yaml:3:36: def bad: invalid operation: + (mismatched types float64 and bool)",
+		},
+		{
+			name: "cycle",
+			content: `
+defs:
+  a: def.b + 1
+  b: def.a + 1
+rules:
+  - name: ok
+    when:
+      condition: "true"
+`,
+			want: "def a: def.b does not compile: def.a refers to itself (a -> b -> a)",
+		},
+		{
+			name: "redefined",
+			content: `
+global:
+  vars:
+    min_buffer: 100
+`,
+			want: "parsing r.yaml:4:5: const.min_buffer is already defined at global.yaml:4:17",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"global.yaml": scopeGlobal, "r.yaml": tt.content})
+			_, err := LoadDir(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLintReportsInvalidDefs(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"r.yaml": `
+defs:
+  bad: account.balance("Checking") + true
+rules:
+  - name: ok
+    when:
+      condition: account.balance("Checking") < 1
+`})
+	results, err := Lint(dir, time.Now())
+	if err != nil {
+		t.Fatalf("lint error: %v", err)
+	}
+	if len(results) != 2 || results[0].Errors() != 0 {
+		t.Fatalf("expected a clean rule and a def result, got %+v", results)
+	}
+	def := results[1]
+	if def.Name != "def.bad" || def.Errors() != 1 || def.Diagnostics[0].Pos.String() != "r.yaml:3:36" {
+		t.Fatalf("unexpected def result %+v", def)
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
+
+	"ynab-alerts/internal/statefile"
 )
 
 // ObservedValue stores the captured value and when it was recorded.
@@ -14,18 +17,90 @@ type ObservedValue struct {
 	RecordedAt time.Time `json:"recorded_at"`
 }
 
-// Store persists observed variables to disk for reuse across runs.
+// AlertState tracks an active (firing) alert for a rule.
+type AlertState struct {
+	FiredAt        time.Time `json:"fired_at"`
+	Acknowledged   bool      `json:"acknowledged,omitempty"`
+	AcknowledgedAt time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string    `json:"acknowledged_by,omitempty"`
+	Escalations    int       `json:"escalations,omitempty"` // escalation steps already sent
+}
+
+// Silence suppresses a rule's alerts until Until, or until removed when
+// Until is zero (muted).
+type Silence struct {
+	Until     time.Time `json:"until,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Muted reports whether the silence lasts until explicitly removed.
+func (s Silence) Muted() bool {
+	return s.Until.IsZero()
+}
+
+// Active reports whether the silence applies at t.
+func (s Silence) Active(t time.Time) bool {
+	return s.Muted() || t.Before(s.Until)
+}
+
+// ClauseState tracks for/clear_condition hysteresis and the last evaluated
+// schedule activation for one when clause.
+type ClauseState struct {
+	PendingSince time.Time `json:"pending_since,omitempty"` // condition true since (consecutive evaluations)
+	Firing       bool      `json:"firing,omitempty"`
+	ScheduledAt  time.Time `json:"scheduled_at,omitempty"` // latest schedule activation evaluated
+}
+
+// equal compares clause states by instant, since times read back from the
+// store differ from in-memory ones in location and monotonic reading.
+func (c ClauseState) equal(o ClauseState) bool {
+	return c.PendingSince.Equal(o.PendingSince) && c.Firing == o.Firing && c.ScheduledAt.Equal(o.ScheduledAt)
+}
+
+// DigestEntry is a digest-mode alert waiting for the next digest. Repeat
+// triggers of the same rule are collapsed into one entry.
+type DigestEntry struct {
+	Rule      string    `json:"rule"`
+	Message   string    `json:"message"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Count     int       `json:"count"`
+}
+
+// Store persists observed variables and alert state to disk for reuse across runs.
 type Store struct {
-	path   string
-	values map[string]ObservedValue
-	mu     sync.Mutex
+	path       string
+	values     map[string]ObservedValue
+	alerts     map[string]AlertState
+	silences   map[string]Silence
+	clauses    map[string]ClauseState
+	digest     []DigestEntry
+	lastDigest time.Time
+	accounts   []string
+	mu         sync.Mutex
+}
+
+// storeFile is the on-disk layout. Older stores hold only the observation map
+// at the top level; load accepts both.
+type storeFile struct {
+	Observations map[string]ObservedValue `json:"observations"`
+	Alerts       map[string]AlertState    `json:"alerts,omitempty"`
+	Silences     map[string]Silence       `json:"silences,omitempty"`
+	Clauses      map[string]ClauseState   `json:"clauses,omitempty"`
+	Digest       []DigestEntry            `json:"digest,omitempty"`
+	LastDigest   time.Time                `json:"last_digest,omitempty"`
+	Accounts     []string                 `json:"accounts,omitempty"` // account names seen on the last poll
 }
 
 // NewStore returns a Store persisted at path.
 func NewStore(path string) (*Store, error) {
 	s := &Store{
-		path:   path,
-		values: map[string]ObservedValue{},
+		path:     path,
+		values:   map[string]ObservedValue{},
+		alerts:   map[string]AlertState{},
+		silences: map[string]Silence{},
+		clauses:  map[string]ClauseState{},
 	}
 	if err := s.load(); err != nil {
 		return nil, err
@@ -33,27 +108,116 @@ func NewStore(path string) (*Store, error) {
 	return s, nil
 }
 
-func (s *Store) load() error {
+// Reload re-reads the store from disk, picking up changes made by other
+// processes such as the ack CLI. The state is swapped in whole, so readers
+// never see it half loaded.
+func (s *Store) Reload() error {
+	file, err := s.read()
+	if err != nil {
+		return err
+	}
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.set(file)
+	s.mu.Unlock()
+	dbg.Debugf("loaded %d observation(s) from %s", len(file.Observations), s.path)
+	return nil
+}
 
+func (s *Store) load() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	return s.Reload()
+}
+
+// read parses the store file into fresh maps. A missing file is empty.
+func (s *Store) read() (storeFile, error) {
+	var file storeFile
 	data, err := os.ReadFile(s.path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
-				return err
+	if err != nil && !os.IsNotExist(err) {
+		return file, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &file); err != nil {
+			return file, err
+		}
+		if file.Observations == nil && file.Alerts == nil && file.Silences == nil && file.Clauses == nil &&
+			file.Digest == nil && file.LastDigest.IsZero() && file.Accounts == nil {
+			// legacy layout: observations at the top level
+			if err := json.Unmarshal(data, &file.Observations); err != nil {
+				return file, err
 			}
-			dbg.Debugf("observation store missing, initializing at %s", s.path)
-			return nil
 		}
+	} else {
+		dbg.Debugf("observation store missing, initializing at %s", s.path)
+	}
+	if file.Observations == nil {
+		file.Observations = map[string]ObservedValue{}
+	}
+	if file.Alerts == nil {
+		file.Alerts = map[string]AlertState{}
+	}
+	if file.Silences == nil {
+		file.Silences = map[string]Silence{}
+	}
+	if file.Clauses == nil {
+		file.Clauses = map[string]ClauseState{}
+	}
+	return file, nil
+}
+
+// set replaces the in-memory state; callers must hold s.mu.
+func (s *Store) set(file storeFile) {
+	s.values = file.Observations
+	s.alerts = file.Alerts
+	s.silences = file.Silences
+	s.clauses = file.Clauses
+	s.digest = file.Digest
+	s.lastDigest = file.LastDigest
+	s.accounts = file.Accounts
+}
+
+// update applies fn to the state on disk and persists it when fn reports a
+// change. The daemon and the CLI commands share the file, so each update
+// holds a lock on it and starts from the latest contents rather than from
+// what this process last read.
+func (s *Store) update(fn func() bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := statefile.Lock(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	file, err := s.read()
+	if err != nil {
 		return err
 	}
+	s.set(file)
+	if !fn() {
+		return nil
+	}
+	return s.persist()
+}
 
-	if err := json.Unmarshal(data, &s.values); err != nil {
+// persist writes the store to disk, replacing the file atomically so
+// concurrent readers see either the old or the new state; callers must hold
+// s.mu and the file lock.
+func (s *Store) persist() error {
+	data, err := json.MarshalIndent(storeFile{
+		Observations: s.values,
+		Alerts:       s.alerts,
+		Silences:     s.silences,
+		Clauses:      s.clauses,
+		Digest:       s.digest,
+		LastDigest:   s.lastDigest,
+		Accounts:     s.accounts,
+	}, "", "  ")
+	if err != nil {
 		return err
 	}
-	dbg.Debugf("loaded %d observation(s) from %s", len(s.values), s.path)
-	return nil
+	return statefile.Write(s.path, data)
 }
 
 // Snapshot returns a copy of stored variables.
@@ -78,17 +242,279 @@ func (s *Store) Get(name string) (ObservedValue, bool) {
 
 // Set writes an observed value and persists it.
 func (s *Store) Set(name string, val ObservedValue) error {
+	err := s.update(func() bool {
+		s.values[name] = val
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	dbg.Debugf("persisted observation %s to %s", name, s.path)
+	return nil
+}
+
+// Alert returns the active alert state for a rule.
+func (s *Store) Alert(rule string) (AlertState, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	a, ok := s.alerts[rule]
+	return a, ok
+}
 
-	s.values[name] = val
-	data, err := json.MarshalIndent(s.values, "", "  ")
+// Alerts returns a copy of all active alert states keyed by rule name.
+func (s *Store) Alerts() map[string]AlertState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]AlertState, len(s.alerts))
+	for k, v := range s.alerts {
+		out[k] = v
+	}
+	return out
+}
+
+// MarkFiring records that a rule is firing, keeping the original fire time
+// (and any acknowledgement) if it was already active.
+func (s *Store) MarkFiring(rule string, at time.Time) (AlertState, error) {
+	var a AlertState
+	err := s.update(func() bool {
+		var ok bool
+		if a, ok = s.alerts[rule]; ok {
+			return false
+		}
+		a = AlertState{FiredAt: at}
+		s.alerts[rule] = a
+		return true
+	})
+	return a, err
+}
+
+// ClearAlert removes the active alert for a rule once it stops firing.
+func (s *Store) ClearAlert(rule string) error {
+	return s.update(func() bool {
+		if _, ok := s.alerts[rule]; !ok {
+			return false
+		}
+		delete(s.alerts, rule)
+		dbg.Debugf("cleared alert state for %s", rule)
+		return true
+	})
+}
+
+// Acknowledge marks the active alert for a rule as acknowledged. It reports
+// false when the rule has no active alert.
+func (s *Store) Acknowledge(rule, by string, at time.Time) (bool, error) {
+	var ok bool
+	err := s.update(func() bool {
+		var a AlertState
+		if a, ok = s.alerts[rule]; !ok {
+			return false
+		}
+		a.Acknowledged = true
+		a.AcknowledgedAt = at
+		a.AcknowledgedBy = by
+		s.alerts[rule] = a
+		dbg.Debugf("acknowledged alert for %s by %q", rule, by)
+		return true
+	})
+	return ok, err
+}
+
+// MarkEscalated records that the first steps escalation steps of a rule's
+// active alert have been sent.
+func (s *Store) MarkEscalated(rule string, steps int) error {
+	return s.update(func() bool {
+		a, ok := s.alerts[rule]
+		if !ok {
+			return false
+		}
+		a.Escalations = steps
+		s.alerts[rule] = a
+		return true
+	})
+}
+
+// Silence stores a snooze or mute for a rule, replacing any existing one.
+func (s *Store) Silence(rule string, sil Silence) error {
+	return s.update(func() bool {
+		s.silences[rule] = sil
+		dbg.Debugf("silenced %s until %s", rule, sil.Until)
+		return true
+	})
+}
+
+// Unsilence removes a rule's snooze or mute. It reports false when the rule
+// was not silenced.
+func (s *Store) Unsilence(rule string) (bool, error) {
+	var ok bool
+	err := s.update(func() bool {
+		if _, ok = s.silences[rule]; ok {
+			delete(s.silences, rule)
+		}
+		return ok
+	})
+	return ok, err
+}
+
+// SilencedAt returns the silence in effect for a rule at t, ignoring
+// expired snoozes without removing them.
+func (s *Store) SilencedAt(rule string, at time.Time) (Silence, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sil, ok := s.silences[rule]
+	if !ok || !sil.Active(at) {
+		return Silence{}, false
+	}
+	return sil, true
+}
+
+// Silenced returns the silence in effect for a rule at t. Expired snoozes are
+// removed.
+func (s *Store) Silenced(rule string, at time.Time) (Silence, bool) {
+	if sil, ok := s.SilencedAt(rule, at); ok {
+		return sil, true
+	}
+	s.mu.Lock()
+	_, expired := s.silences[rule]
+	s.mu.Unlock()
+	if !expired {
+		return Silence{}, false
+	}
+	var active bool
+	var sil Silence
+	err := s.update(func() bool {
+		var ok bool
+		if sil, ok = s.silences[rule]; !ok {
+			return false
+		}
+		if active = sil.Active(at); active {
+			return false
+		}
+		delete(s.silences, rule)
+		dbg.Debugf("snooze for %s expired at %s", rule, sil.Until.Format(time.RFC3339))
+		return true
+	})
 	if err != nil {
-		return err
+		dbg.Debugf("persisting expired snooze for %s failed: %v", rule, err)
 	}
-	if err := os.WriteFile(s.path, data, 0o644); err != nil {
-		return err
+	if !active {
+		return Silence{}, false
 	}
-	dbg.Debugf("persisted observation %s to %s", name, s.path)
-	return nil
+	return sil, true
+}
+
+// Silences returns a copy of all stored silences keyed by rule name,
+// including expired snoozes not yet removed.
+func (s *Store) Silences() map[string]Silence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]Silence, len(s.silences))
+	for k, v := range s.silences {
+		out[k] = v
+	}
+	return out
+}
+
+// Clause returns the state kept for a when clause.
+func (s *Store) Clause(key string) ClauseState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clauses[key]
+}
+
+// SetClause records the state kept for a when clause, persisting only
+// when it changed. A zero state removes the entry.
+func (s *Store) SetClause(key string, st ClauseState) error {
+	return s.update(func() bool {
+		if s.clauses[key].equal(st) {
+			return false
+		}
+		if st.equal(ClauseState{}) {
+			delete(s.clauses, key)
+		} else {
+			s.clauses[key] = st
+		}
+		return true
+	})
+}
+
+// AddDigest queues a digest-mode alert, collapsing repeats of the same rule.
+func (s *Store) AddDigest(rule, message string, at time.Time) error {
+	return s.update(func() bool {
+		for i, e := range s.digest {
+			if e.Rule == rule {
+				s.digest[i].Message = message
+				s.digest[i].LastSeen = at
+				s.digest[i].Count++
+				return true
+			}
+		}
+		s.digest = append(s.digest, DigestEntry{Rule: rule, Message: message, FirstSeen: at, LastSeen: at, Count: 1})
+		return true
+	})
+}
+
+// LastDigest returns when the last digest was sent (zero if never).
+func (s *Store) LastDigest() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastDigest
+}
+
+// StartDigest records at as the digest starting point if none exists yet,
+// keeping any queued entries.
+func (s *Store) StartDigest(at time.Time) error {
+	return s.update(func() bool {
+		if !s.lastDigest.IsZero() {
+			return false
+		}
+		s.lastDigest = at
+		return true
+	})
+}
+
+// FlushDigest returns the queued digest entries, clears them and records at
+// as the last digest time.
+func (s *Store) FlushDigest(at time.Time) ([]DigestEntry, error) {
+	var entries []DigestEntry
+	err := s.update(func() bool {
+		entries = s.digest
+		s.digest = nil
+		s.lastDigest = at
+		return true
+	})
+	return entries, err
+}
+
+// Accounts returns the account names recorded by the last poll.
+func (s *Store) Accounts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.accounts...)
+}
+
+// SetAccounts records the account names seen by a poll, persisting only when
+// they changed.
+func (s *Store) SetAccounts(names []string) error {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+
+	return s.update(func() bool {
+		if len(sorted) == len(s.accounts) {
+			same := true
+			for i := range sorted {
+				if sorted[i] != s.accounts[i] {
+					same = false
+					break
+				}
+			}
+			if same {
+				return false
+			}
+		}
+		s.accounts = sorted
+		return true
+	})
 }
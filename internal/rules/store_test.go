@@ -0,0 +1,141 @@
+package rules
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStoreLoadsLegacyLayout(t *testing.T) {
+	path := t.TempDir() + "/obs.json"
+	legacy := `{"cc_due": {"value": 12000, "recorded_at": "2024-03-05T09:00:00Z"}}`
+	if err := os.WriteFile(path, []byte(legacy), 0o644); err != nil {
+		t.Fatalf("write legacy store: %v", err)
+	}
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	if got := store.Snapshot()["cc_due"]; got != 12000 {
+		t.Fatalf("expected legacy observation to load, got %d", got)
+	}
+}
+
+func TestStoreAlertLifecycle(t *testing.T) {
+	path := t.TempDir() + "/obs.json"
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	first := time.Date(2024, time.March, 14, 9, 0, 0, 0, time.UTC)
+	if _, err := store.MarkFiring("low", first); err != nil {
+		t.Fatalf("mark firing: %v", err)
+	}
+	if a, _ := store.MarkFiring("low", first.Add(time.Hour)); !a.FiredAt.Equal(first) {
+		t.Fatalf("expected original fire time to be kept, got %s", a.FiredAt)
+	}
+	if ok, err := store.Acknowledge("low", "phone", first.Add(time.Minute)); err != nil || !ok {
+		t.Fatalf("acknowledge: ok=%v err=%v", ok, err)
+	}
+	if ok, _ := store.Acknowledge("other", "phone", first); ok {
+		t.Fatalf("expected no ack for inactive rule")
+	}
+
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	a, ok := reloaded.Alert("low")
+	if !ok || !a.Acknowledged || a.AcknowledgedBy != "phone" {
+		t.Fatalf("expected persisted acknowledged alert, got %+v", a)
+	}
+	if err := reloaded.ClearAlert("low"); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if _, ok := reloaded.Alert("low"); ok {
+		t.Fatalf("expected alert to be cleared")
+	}
+}
+
+func TestStoreReloadSeesOtherWriters(t *testing.T) {
+	path := t.TempDir() + "/obs.json"
+	daemon, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	at := time.Date(2024, time.March, 14, 9, 0, 0, 0, time.UTC)
+	if _, err := daemon.MarkFiring("low", at); err != nil {
+		t.Fatalf("mark firing: %v", err)
+	}
+	cli, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	if ok, err := cli.Acknowledge("low", "cli", at); err != nil || !ok {
+		t.Fatalf("acknowledge: ok=%v err=%v", ok, err)
+	}
+	if err := daemon.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if a, _ := daemon.Alert("low"); !a.Acknowledged || a.AcknowledgedBy != "cli" {
+		t.Fatalf("expected reload to pick up acknowledgement, got %+v", a)
+	}
+}
+
+func TestStoreAccountsCache(t *testing.T) {
+	path := t.TempDir() + "/obs.json"
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	if err := store.SetAccounts([]string{"Savings", "Checking"}); err != nil {
+		t.Fatalf("set accounts: %v", err)
+	}
+	reopened, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	if got := reopened.Accounts(); len(got) != 2 || got[0] != "Checking" || got[1] != "Savings" {
+		t.Fatalf("expected sorted cached accounts, got %v", got)
+	}
+}
+
+func TestStoreUpdatesKeepOtherWriters(t *testing.T) {
+	path := t.TempDir() + "/obs.json"
+	daemon, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	at := time.Date(2024, time.March, 14, 9, 0, 0, 0, time.UTC)
+	if _, err := daemon.MarkFiring("low", at); err != nil {
+		t.Fatalf("mark firing: %v", err)
+	}
+	cli, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	if ok, err := cli.Acknowledge("low", "cli", at); err != nil || !ok {
+		t.Fatalf("acknowledge: ok=%v err=%v", ok, err)
+	}
+	if err := cli.Silence("noisy", Silence{CreatedAt: at}); err != nil {
+		t.Fatalf("silence: %v", err)
+	}
+
+	// the daemon writes without reloading first; the CLI's changes survive
+	if err := daemon.Set("rent", ObservedValue{Value: 1, RecordedAt: at}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	reopened, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	if a, _ := reopened.Alert("low"); !a.Acknowledged {
+		t.Fatalf("acknowledgement lost by a later write: %+v", a)
+	}
+	if _, ok := reopened.Silences()["noisy"]; !ok {
+		t.Fatalf("silence lost by a later write")
+	}
+	if _, ok := reopened.Get("rent"); !ok {
+		t.Fatalf("expected observation to be persisted")
+	}
+}
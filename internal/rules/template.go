@@ -0,0 +1,200 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ruleTemplate is a parameterized rule from a file's templates section. Its
+// string values may use text/template actions such as {{ .card }}, filled
+// from the args of each instance.
+type ruleTemplate struct {
+	node  *yaml.Node
+	file  string
+	pos   Position
+	scope *scope // constants and defs of the template's file
+}
+
+// templateInstance is a rule list entry that expands a template:
+//
+//   - template: card_payment_readiness
+//     name: visa_payment_readiness # optional, overrides the template's name
+//     args: {card: Visa, capture_day: 5}
+type templateInstance struct {
+	Template string                 `yaml:"template"`
+	Name     string                 `yaml:"name,omitempty"`
+	Args     map[string]interface{} `yaml:"args,omitempty"`
+
+	index int // slot in loader.rules the expanded rule replaces
+	pos   Position
+}
+
+// instanceEntry reports whether n is a template instance rather than a rule.
+func instanceEntry(n *yaml.Node) (*templateInstance, bool, error) {
+	if mappingValue(n, "template") == nil {
+		return nil, false, nil
+	}
+	inst := &templateInstance{pos: Position{Line: n.Line, Column: n.Column}}
+	if err := n.Decode(inst); err != nil {
+		return nil, true, err
+	}
+	if keys := unknownKeys(n, templateInstance{}); len(keys) > 0 {
+		return nil, true, errors.New(keys[0].String())
+	}
+	if strings.TrimSpace(inst.Template) == "" {
+		return nil, true, errors.New("template name is empty")
+	}
+	return inst, true, nil
+}
+
+// addTemplates registers the templates of mapping n, defined in file with
+// scope sc.
+func (l *loader) addTemplates(file string, sc *scope, n *yaml.Node) error {
+	if n.Kind != yaml.MappingNode {
+		return &parseError{Pos: nodePos(file, n), Err: errors.New("templates must map names to rules")}
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, body := n.Content[i], n.Content[i+1]
+		pos := nodePos(file, key)
+		if first, ok := l.templates[key.Value]; ok {
+			return fmt.Errorf("%stemplate %s: duplicate template name, first defined at %s", posPrefix(pos), key.Value, first.pos)
+		}
+		if body.Kind != yaml.MappingNode {
+			return fmt.Errorf("%stemplate %s: must be a rule", posPrefix(pos), key.Value)
+		}
+		l.templates[key.Value] = ruleTemplate{node: body, file: file, pos: pos, scope: sc}
+	}
+	return nil
+}
+
+// expand replaces every template instance with its rule. Templates are
+// shared by all files, so expansion waits until everything is loaded.
+func (l *loader) expand() error {
+	for _, inst := range l.instances {
+		tmpl, ok := l.templates[inst.Template]
+		if !ok {
+			names := make([]string, 0, len(l.templates))
+			for name := range l.templates {
+				names = append(names, name)
+			}
+			msg := fmt.Sprintf("unknown template %q", inst.Template)
+			if match, ok := closestName(inst.Template, names); ok {
+				msg += fmt.Sprintf(" (did you mean %q?)", match)
+			}
+			return fmt.Errorf("%s%s", posPrefix(inst.pos), msg)
+		}
+		r, err := tmpl.instantiate(inst)
+		if err != nil {
+			return err
+		}
+		l.rules[inst.index] = r
+	}
+	return nil
+}
+
+// instantiate fills the template with the instance's args and decodes the
+// result. Expression positions, constants and defs come from the template's
+// file; the rule itself is positioned at the instance.
+func (t ruleTemplate) instantiate(inst *templateInstance) (Rule, error) {
+	node := cloneNode(t.node)
+	if err := fillTemplate(node, inst.Args); err != nil {
+		var te *templateError
+		if errors.As(err, &te) {
+			te.Pos.File = t.file
+			return Rule{}, fmt.Errorf("%stemplate %s (instance at %s): %s", posPrefix(te.Pos), inst.Template, inst.pos, te.Msg)
+		}
+		return Rule{}, fmt.Errorf("%stemplate %s: %w", posPrefix(inst.pos), inst.Template, err)
+	}
+	var r Rule
+	if err := node.Decode(&r); err != nil {
+		return Rule{}, fmt.Errorf("%stemplate %s: %w", posPrefix(inst.pos), inst.Template, err)
+	}
+	r.setFile(t.file)
+	r.scope = t.scope
+	r.Pos = inst.pos
+	if inst.Name != "" {
+		r.Name = inst.Name
+	}
+	return r, nil
+}
+
+// wholeAction matches a scalar that is a single template action, whose
+// result is re-read as YAML so "{{ .day }}" can fill an int field.
+var wholeAction = regexp.MustCompile(`^\s*\{\{[^{}]*\}\}\s*$`)
+
+// fillTemplate executes every templated scalar value under n with args.
+// Mapping keys are left alone.
+func fillTemplate(n *yaml.Node, args map[string]interface{}) error {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(n.Value, "{{") {
+			return nil
+		}
+		tmpl, err := template.New("").Option("missingkey=error").Parse(n.Value)
+		if err != nil {
+			return newTemplateError(n, err)
+		}
+		var out strings.Builder
+		if err := tmpl.Execute(&out, args); err != nil {
+			return newTemplateError(n, err)
+		}
+		if wholeAction.MatchString(n.Value) {
+			n.Tag, n.Style = "", 0
+		}
+		n.Value = out.String()
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			if err := fillTemplate(n.Content[i], args); err != nil {
+				return err
+			}
+		}
+	default:
+		for _, c := range n.Content {
+			if err := fillTemplate(c, args); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// templateError is a templated value that does not parse or execute, e.g.
+// because the instance lacks an argument.
+type templateError struct {
+	Pos Position
+	Msg string
+}
+
+func (e *templateError) Error() string {
+	return posPrefix(e.Pos) + e.Msg
+}
+
+// templatePrefix is the location text/template puts before its messages.
+var templatePrefix = regexp.MustCompile(`^template: [^ ]*: (executing "" at <[^>]*>: )?`)
+
+func newTemplateError(n *yaml.Node, err error) error {
+	msg := templatePrefix.ReplaceAllString(err.Error(), "")
+	if m := missingKey.FindStringSubmatch(msg); m != nil {
+		msg = fmt.Sprintf("missing argument %q", m[1])
+	}
+	return &templateError{Pos: scalarPosition(n), Msg: msg}
+}
+
+var missingKey = regexp.MustCompile(`^map has no entry for key "([^"]*)"$`)
+
+func cloneNode(n *yaml.Node) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = cloneNode(child)
+	}
+	return &c
+}
@@ -0,0 +1,99 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadDirExpandsTemplates(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"templates.yaml": `
+templates:
+  card_payment_readiness:
+    name: "{{ .card }}_payment_readiness"
+    observe:
+      - capture_on: "{{ .capture_day }}"
+        variable: due
+        value: account.due("{{ .card }}")
+    when:
+      - day_of_month: ["{{ .check_day }}"]
+        condition: account.balance("Checking") < 0.8 * var.due
+    notify: [log]
+`,
+		"cards/instances.yaml": `
+- template: card_payment_readiness
+  args: {card: Visa, capture_day: 5, check_day: 14}
+- template: card_payment_readiness
+  name: amex_ready
+  args: {card: Amex, capture_day: -1, check_day: 20}
+`,
+	})
+	rs, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if got := strings.Join(ruleNames(rs), ","); got != "Visa_payment_readiness,amex_ready" {
+		t.Fatalf("unexpected rules %s", got)
+	}
+	visa, amex := rs[0], rs[1]
+	if visa.Observe[0].CaptureOn != "5" || visa.Observe[0].Value != `account.due("Visa")` {
+		t.Fatalf("unexpected observe %+v", visa.Observe[0])
+	}
+	if visa.When[0].DayOfMonth[0] != 14 || amex.When[0].DayOfMonth[0] != 20 {
+		t.Fatalf("expected templated days 14 and 20, got %v and %v", visa.When[0].DayOfMonth, amex.When[0].DayOfMonth)
+	}
+	if amex.Observe[0].Value != `account.due("Amex")` || amex.Observe[0].CaptureOn != "-1" {
+		t.Fatalf("unexpected observe %+v", amex.Observe[0])
+	}
+	if got := amex.Pos.String(); got != "cards/instances.yaml:4:3" {
+		t.Fatalf("expected rule positioned at the instance, got %s", got)
+	}
+	if got := amex.When[0].conditionPos.String(); got != "templates.yaml:11:20" {
+		t.Fatalf("expected condition positioned in the template, got %s", got)
+	}
+	if amex.When[0].condition == nil {
+		t.Fatalf("expected expanded rules to be compiled")
+	}
+}
+
+func TestLoadDirTemplateErrors(t *testing.T) {
+	tmpl := `
+templates:
+  buffer:
+    name: "{{ .account }}_buffer"
+    when:
+      condition: account.balance("{{ .account }}") < {{ .min }}
+    notify: [log]
+`
+	for _, tt := range []struct {
+		name     string
+		rules    string
+		contains string
+	}{
+		{
+			name:     "missing argument",
+			rules:    "- template: buffer\n  args: {account: Checking}\n",
+			contains: `t.yaml:6:18: template buffer (instance at r.yaml:1:3): missing argument "min"`,
+		},
+		{
+			name:     "unknown template",
+			rules:    "- template: bufer\n  args: {account: Checking, min: 1}\n",
+			contains: `r.yaml:1:3: unknown template "bufer" (did you mean "buffer"?)`,
+		},
+		{
+			name:     "unknown instance key",
+			rules:    "- template: buffer\n  arg: {account: Checking, min: 1}\n",
+			contains: `unknown field "arg" (did you mean "args"?)`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"t.yaml": tmpl, "r.yaml": tt.rules})
+			_, err := LoadDir(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.contains) {
+				t.Fatalf("expected error containing %q, got %v", tt.contains, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"ynab-alerts/internal/daytime"
+)
+
+// timeWindow is a parsed When.Window: an optional set of weekdays and a daily
+// time-of-day range. An End before Start wraps past midnight; the wrapped part
+// belongs to the previous day's window.
+type timeWindow struct {
+	Days  map[time.Weekday]bool // nil = every day
+	Start time.Duration         // offset from midnight
+	End   time.Duration
+}
+
+// parseWindow parses "HH:MM-HH:MM", optionally preceded by weekdays such as
+// "Mon-Fri", "Sat,Sun" or "Tue".
+func parseWindow(val string) (timeWindow, error) {
+	fields := strings.Fields(val)
+	var w timeWindow
+	switch len(fields) {
+	case 1:
+	case 2:
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return timeWindow{}, err
+		}
+		w.Days = days
+	default:
+		return timeWindow{}, fmt.Errorf("window %q must be \"HH:MM-HH:MM\" or \"<days> HH:MM-HH:MM\"", val)
+	}
+	start, end, ok := strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return timeWindow{}, fmt.Errorf("window %q has no HH:MM-HH:MM range", val)
+	}
+	var err error
+	if w.Start, err = daytime.Parse(start); err != nil {
+		return timeWindow{}, fmt.Errorf("window %q: %w", val, err)
+	}
+	if w.End, err = daytime.Parse(end); err != nil {
+		return timeWindow{}, fmt.Errorf("window %q: %w", val, err)
+	}
+	if w.Start == w.End {
+		return timeWindow{}, fmt.Errorf("window %q start and end are equal", val)
+	}
+	return w, nil
+}
+
+// parseWeekdays parses "Mon-Fri", "Fri-Mon", "Sat,Sun" or a single day.
+func parseWeekdays(val string) (map[time.Weekday]bool, error) {
+	days := map[time.Weekday]bool{}
+	for _, part := range strings.Split(val, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		start, ok := weekdayMap[strings.ToLower(strings.TrimSpace(from))]
+		if !ok {
+			return nil, fmt.Errorf("window weekday %q is invalid", from)
+		}
+		end := start
+		if isRange {
+			if end, ok = weekdayMap[strings.ToLower(strings.TrimSpace(to))]; !ok {
+				return nil, fmt.Errorf("window weekday %q is invalid", to)
+			}
+		}
+		for d := start; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == end {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// Length returns how long the window is open each day it applies.
+func (w timeWindow) Length() time.Duration {
+	if w.End > w.Start {
+		return w.End - w.Start
+	}
+	return 24*time.Hour - w.Start + w.End
+}
+
+// Contains reports whether t falls inside the window.
+func (w timeWindow) Contains(t time.Time) bool {
+	offset := daytime.Offset(t)
+	day := t.Weekday()
+	if w.Start < w.End {
+		return offset >= w.Start && offset < w.End && w.onDay(day)
+	}
+	if offset >= w.Start {
+		return w.onDay(day)
+	}
+	// after midnight: part of the previous day's window
+	return offset < w.End && w.onDay((day+6)%7)
+}
+
+func (w timeWindow) onDay(d time.Weekday) bool {
+	return w.Days == nil || w.Days[d]
+}
+
+// OpensOn returns when the window opens on t's calendar day.
+func (w timeWindow) OpensOn(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(w.Start)
+}
+
+// withinWindow reports whether now is inside when's window. Clauses without
+// a window always match; invalid windows (reported by lint) never do.
+func withinWindow(when When, now time.Time) bool {
+	if strings.TrimSpace(when.Window) == "" {
+		return true
+	}
+	w, err := parseWindow(when.Window)
+	if err != nil {
+		return false
+	}
+	return w.Contains(now)
+}
@@ -0,0 +1,133 @@
+package rules
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseWindowContains(t *testing.T) {
+	// 2024-01-05 is a Friday
+	at := func(day, h, m int) time.Time { return time.Date(2024, time.January, day, h, m, 0, 0, time.UTC) }
+	for _, tt := range []struct {
+		window string
+		t      time.Time
+		expect bool
+	}{
+		{"08:00-10:00", at(5, 8, 0), true},
+		{"08:00-10:00", at(5, 10, 0), false},
+		{"Mon-Fri 09:00-17:00", at(5, 12, 0), true},
+		{"Mon-Fri 09:00-17:00", at(6, 12, 0), false},
+		{"Sat,Sun 10:00-12:00", at(6, 11, 0), true},
+		{"Fri 22:00-02:00", at(5, 23, 0), true},
+		{"Fri 22:00-02:00", at(6, 1, 0), true}, // Saturday 01:00 belongs to Friday's window
+		{"Fri 22:00-02:00", at(5, 1, 0), false},
+		{"Fri-Mon 08:00-09:00", at(7, 8, 30), true},
+	} {
+		w, err := parseWindow(tt.window)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.window, err)
+		}
+		if got := w.Contains(tt.t); got != tt.expect {
+			t.Fatalf("%s at %s: expected %v got %v", tt.window, tt.t.Format("Mon 15:04"), tt.expect, got)
+		}
+	}
+	for _, bad := range []string{"8am-10am", "Funday 08:00-10:00", "08:00-08:00", "Mon 08:00 10:00"} {
+		if _, err := parseWindow(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestEvaluateWindowGate(t *testing.T) {
+	r := Rule{
+		Name: "business_hours",
+		When: WhenList{{Window: "Mon-Fri 09:00-17:00", Condition: `account.balance("Checking") < 100`}},
+	}
+	fires := func(now time.Time) bool {
+		data := Data{Accounts: map[string]int64{"Checking": 50_000}, Vars: map[string]int64{}, Now: now}
+		trigs, err := Evaluate(context.Background(), []Rule{r}, nil, data)
+		if err != nil {
+			t.Fatalf("evaluate error: %v", err)
+		}
+		return len(trigs) == 1
+	}
+	if !fires(time.Date(2024, time.January, 5, 9, 30, 0, 0, time.UTC)) {
+		t.Fatalf("expected rule to fire inside the window")
+	}
+	if fires(time.Date(2024, time.January, 5, 18, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected rule to be skipped after the window")
+	}
+}
+
+func TestLintWindowNextEval(t *testing.T) {
+	dir := t.TempDir()
+	content := `
+- name: morning
+  when:
+    window: "Mon-Fri 08:00-10:00"
+    condition: account.balance("Checking") < 100
+- name: narrow
+  when:
+    window: "08:00-08:15"
+    condition: account.balance("Checking") < 100
+`
+	if err := os.WriteFile(filepath.Join(dir, "r.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	// Friday 11:00: today's window has closed, next is Monday 08:00 + poll
+	now := time.Date(2024, time.January, 5, 11, 0, 0, 0, time.UTC)
+	results, err := LintWithPoll(dir, now, 30*time.Minute)
+	if err != nil {
+		t.Fatalf("lint error: %v", err)
+	}
+	want := time.Date(2024, time.January, 8, 8, 30, 0, 0, time.UTC)
+	if !results[0].HasNext || !results[0].NextEval.Equal(want) {
+		t.Fatalf("expected next eval %s, got %s", want, results[0].NextEval)
+	}
+	if len(results[0].Issues) != 0 {
+		t.Fatalf("unexpected issues: %v", results[0].Issues)
+	}
+	if len(results[1].Issues) != 1 {
+		t.Fatalf("expected poll interval issue for narrow window, got %v", results[1].Issues)
+	}
+}
+
+func TestEvaluateRulesUsesRuleTimezone(t *testing.T) {
+	cond := `account.balance("Checking") < 100`
+	rs := []Rule{
+		{Name: "utc", When: WhenList{{DayOfMonth: []int{1}, Window: "18:00-23:00", Condition: cond}}},
+		{Name: "chicago", Timezone: "America/Chicago", When: WhenList{{DayOfMonth: []int{1}, Window: "18:00-23:00", Condition: cond}}},
+	}
+	// 2024-03-02 02:00 UTC is 2024-03-01 20:00 in Chicago
+	now := time.Date(2024, time.March, 2, 2, 0, 0, 0, time.UTC)
+	data := Data{Accounts: map[string]int64{"Checking": 50_000}, Vars: map[string]int64{}, Now: now}
+	res, err := EvaluateRules(context.Background(), rs, nil, data)
+	if err != nil {
+		t.Fatalf("evaluate error: %v", err)
+	}
+	if len(res.Triggers) != 1 || res.Triggers[0].Rule.Name != "chicago" {
+		t.Fatalf("expected only the Chicago rule to fire, got %+v", res.Triggers)
+	}
+
+	chicago, _ := time.LoadLocation("America/Chicago")
+	if !sameCalendarDay(now, time.Date(2024, time.March, 1, 12, 0, 0, 0, chicago)) {
+		t.Fatalf("expected sameCalendarDay to compare in the second time's zone")
+	}
+}
+
+func TestLoadDirRejectsInvalidTimezone(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"r.yaml": `
+- name: mars
+  timezone: Mars/Olympus
+  when:
+    condition: "true"
+`})
+	_, err := LoadDir(dir)
+	if want := `r.yaml:2:3: rule mars: timezone "Mars/Olympus" is invalid`; err == nil || err.Error() != want {
+		t.Fatalf("expected %q, got %v", want, err)
+	}
+}
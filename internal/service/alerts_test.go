@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"ynab-alerts/internal/rules"
+)
+
+func TestTrackAlertsLifecycle(t *testing.T) {
+	store, err := rules.NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	svc := &Service{ruleStore: store}
+	now := time.Date(2024, time.March, 14, 9, 0, 0, 0, time.UTC)
+
+	res := rules.Result{
+		Triggers: []rules.Trigger{{Rule: rules.Rule{Name: "low"}}},
+		Checked:  []string{"low", "ok"},
+	}
+	if acked := svc.trackAlerts(res, now); acked["low"] {
+		t.Fatalf("new alert should not be acknowledged")
+	}
+	if state, ok := store.Alert("low"); !ok || state.Acknowledged {
+		t.Fatalf("expected a new unacknowledged alert, got %+v", state)
+	}
+	if _, err := store.Acknowledge("low", "phone", now); err != nil {
+		t.Fatalf("acknowledge: %v", err)
+	}
+	if acked := svc.trackAlerts(res, now.Add(time.Hour)); !acked["low"] {
+		t.Fatalf("expected acknowledged alert to be reported")
+	}
+	if state, _ := store.Alert("low"); !state.Acknowledged || !state.FiredAt.Equal(now) {
+		t.Fatalf("expected acknowledgement to persist while firing, got %+v", state)
+	}
+
+	svc.trackAlerts(rules.Result{Checked: []string{"low"}}, now.Add(2*time.Hour))
+	if _, ok := store.Alert("low"); ok {
+		t.Fatalf("expected alert to clear once the rule stops firing")
+	}
+}
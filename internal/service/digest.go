@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"ynab-alerts/internal/config"
+	"ynab-alerts/internal/notifier"
+	"ynab-alerts/internal/rules"
+)
+
+// digestRule is the pseudo rule name used for digest alerts.
+const digestRule = "digest"
+
+// queueDigest records a digest-mode trigger for the next digest.
+func (s *Service) queueDigest(trig rules.Trigger, now time.Time) {
+	if s.ruleStore == nil {
+		log.Printf("digest delivery for %s needs an observation store; dropping", trig.Rule.Name)
+		return
+	}
+	s.debugf("queueing rule %s for digest", trig.Rule.Name)
+	if err := s.ruleStore.AddDigest(trig.Rule.Name, trig.Message, now); err != nil {
+		log.Printf("digest queue failed for %s: %v", trig.Rule.Name, err)
+	}
+}
+
+// digestAfter returns the first digest time of the configured schedule
+// after last, read in the configured timezone so the digest keeps its wall
+// clock time across DST changes.
+func (s *Service) digestAfter(last time.Time) (time.Time, error) {
+	spec := s.cfg.Digest.Schedule
+	if spec == "" {
+		spec = config.DefaultDigestSchedule()
+	}
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("digest schedule %q invalid: %w", spec, err)
+	}
+	return sched.Next(last.In(s.cfg.Location())), nil
+}
+
+// nextDigest returns when the next digest is due, once the first run has
+// recorded a starting point.
+func (s *Service) nextDigest() (time.Time, bool) {
+	if s.ruleStore == nil {
+		return time.Time{}, false
+	}
+	last := s.ruleStore.LastDigest()
+	if last.IsZero() {
+		return time.Time{}, false
+	}
+	next, err := s.digestAfter(last)
+	return next, err == nil
+}
+
+// maybeSendDigest sends the digest once its schedule has passed since the
+// previous digest. The first run only records a starting point.
+func (s *Service) maybeSendDigest(ctx context.Context, now time.Time, ruleDefs []rules.Rule, balances map[string]int64) {
+	if s.ruleStore == nil {
+		return
+	}
+	last := s.ruleStore.LastDigest()
+	next, err := s.digestAfter(last)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if last.IsZero() {
+		if err := s.ruleStore.StartDigest(now); err != nil {
+			log.Printf("digest init failed: %v", err)
+		}
+		return
+	}
+	if next.After(now) {
+		return
+	}
+
+	entries, err := s.ruleStore.FlushDigest(now)
+	if err != nil {
+		log.Printf("digest flush failed: %v", err)
+		return
+	}
+	var digestRules []rules.Rule
+	for _, r := range ruleDefs {
+		if r.IsDigest() {
+			digestRules = append(digestRules, r)
+		}
+	}
+	if len(entries) == 0 && !s.cfg.Digest.AllClear {
+		s.debugf("no digest entries since %s", last.Format(time.RFC3339))
+		return
+	}
+
+	// each channel once, however many digest rules name it; with none named
+	// the digest goes to every channel
+	var channels []string
+	seen := map[string]bool{}
+	for _, r := range digestRules {
+		for _, name := range r.Notify {
+			if !seen[name] {
+				seen[name] = true
+				channels = append(channels, name)
+			}
+		}
+	}
+	alert := notifier.Alert{
+		Rule:    digestRule,
+		Subject: "YNAB alerts digest",
+		Message: formatDigest(entries, digestRules, balances, last),
+		Budget:  s.cfg.BudgetID,
+		Time:    now,
+	}
+	if err := s.deliver(ctx, alert, channels); err != nil {
+		log.Printf("digest delivery failed: %v", err)
+	}
+	log.Printf("sent digest with %d alert(s)", len(entries))
+}
+
+func formatDigest(entries []rules.DigestEntry, digestRules []rules.Rule, balances map[string]int64, since time.Time) string {
+	var b strings.Builder
+	if len(entries) == 0 {
+		fmt.Fprintf(&b, "All clear: no alerts since %s.\n", since.Format("Mon Jan 2 15:04"))
+	} else {
+		fmt.Fprintf(&b, "%d alert(s) since %s:\n", len(entries), since.Format("Mon Jan 2 15:04"))
+		for _, e := range entries {
+			fmt.Fprintf(&b, "- %s: %s", e.Rule, e.Message)
+			if e.Count > 1 {
+				fmt.Fprintf(&b, " (%d times since %s)", e.Count, e.FirstSeen.Format("Jan 2 15:04"))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	seen := map[string]bool{}
+	var accounts []string
+	for _, r := range digestRules {
+		for _, name := range r.AccountRefs() {
+			if !seen[name] {
+				seen[name] = true
+				accounts = append(accounts, name)
+			}
+		}
+	}
+	sort.Strings(accounts)
+	if len(accounts) > 0 {
+		b.WriteString("\nBalances:\n")
+		for _, name := range accounts {
+			if bal, ok := balances[name]; ok {
+				fmt.Fprintf(&b, "- %s: %.2f\n", name, float64(bal)/1000)
+			} else {
+				fmt.Fprintf(&b, "- %s: unknown\n", name)
+			}
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"ynab-alerts/internal/config"
+	"ynab-alerts/internal/notifier"
+	"ynab-alerts/internal/rules"
+)
+
+type capturingNotifier struct {
+	alerts []notifier.Alert
+}
+
+func (c *capturingNotifier) Notify(_ context.Context, subject, message string) error {
+	c.alerts = append(c.alerts, notifier.Alert{Subject: subject, Message: message})
+	return nil
+}
+
+func TestDigestBatchesAndSendsOnSchedule(t *testing.T) {
+	store, err := rules.NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	capture := &capturingNotifier{}
+	svc := &Service{
+		cfg:       config.Config{Digest: config.DigestConfig{Schedule: "0 8 * * *"}},
+		notifier:  capture,
+		ruleStore: store,
+	}
+	rule := rules.Rule{
+		Name:     "low_checking",
+		Delivery: "digest",
+		When:     rules.WhenList{{Condition: `account.balance("Checking") < 100`}},
+	}
+	balances := map[string]int64{"Checking": 42_500}
+	ctx := context.Background()
+
+	start := time.Date(2024, time.March, 14, 6, 0, 0, 0, time.UTC)
+	svc.queueDigest(rules.Trigger{Rule: rule, Message: "low"}, start)
+	svc.maybeSendDigest(ctx, start, []rules.Rule{rule}, balances)
+	svc.queueDigest(rules.Trigger{Rule: rule, Message: "still low"}, start.Add(time.Hour))
+	svc.maybeSendDigest(ctx, start.Add(time.Hour), []rules.Rule{rule}, balances)
+	if len(capture.alerts) != 0 {
+		t.Fatalf("digest sent before schedule: %+v", capture.alerts)
+	}
+
+	svc.maybeSendDigest(ctx, start.Add(2*time.Hour), []rules.Rule{rule}, balances)
+	if len(capture.alerts) != 1 {
+		t.Fatalf("expected one digest, got %d", len(capture.alerts))
+	}
+	msg := capture.alerts[0].Message
+	for _, want := range []string{"1 alert(s)", "low_checking: still low (2 times", "Checking: 42.50"} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("digest missing %q:\n%s", want, msg)
+		}
+	}
+
+	// nothing fired: no all-clear unless enabled
+	next := start.Add(26 * time.Hour)
+	svc.maybeSendDigest(ctx, next, []rules.Rule{rule}, balances)
+	if len(capture.alerts) != 1 {
+		t.Fatalf("unexpected empty digest")
+	}
+	svc.cfg.Digest.AllClear = true
+	svc.maybeSendDigest(ctx, next.Add(24*time.Hour), []rules.Rule{rule}, balances)
+	if len(capture.alerts) != 2 || !strings.HasPrefix(capture.alerts[1].Message, "All clear") {
+		t.Fatalf("expected all-clear digest, got %+v", capture.alerts)
+	}
+}
+
+func TestDigestSendsOncePerChannelAndAllClearWithoutDigestRules(t *testing.T) {
+	store, err := rules.NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	capture := &capturingNotifier{}
+	fanout := notifier.NewFanout([]notifier.Channel{{Name: "log", Notifier: capture}}, nil, notifier.RetryPolicy{})
+	svc := &Service{
+		cfg:       config.Config{Digest: config.DigestConfig{Schedule: "0 8 * * *", AllClear: true}},
+		notifier:  fanout,
+		ruleStore: store,
+	}
+	ctx := context.Background()
+	start := time.Date(2024, time.March, 14, 6, 0, 0, 0, time.UTC)
+	svc.maybeSendDigest(ctx, start, nil, nil)
+
+	// no rule opts into the digest: the all-clear still goes out
+	svc.maybeSendDigest(ctx, start.Add(2*time.Hour), nil, nil)
+	if len(capture.alerts) != 1 || !strings.HasPrefix(capture.alerts[0].Message, "All clear") {
+		t.Fatalf("expected an all-clear digest, got %+v", capture.alerts)
+	}
+
+	a := rules.Rule{Name: "a", Delivery: "digest", Notify: []string{"log"}}
+	b := rules.Rule{Name: "b", Delivery: "digest", Notify: []string{"log"}}
+	svc.queueDigest(rules.Trigger{Rule: a, Message: "low"}, start.Add(3*time.Hour))
+	svc.maybeSendDigest(ctx, start.Add(26*time.Hour), []rules.Rule{a, b}, nil)
+	if len(capture.alerts) != 2 {
+		t.Fatalf("expected one digest for the channel both rules name, got %d", len(capture.alerts)-1)
+	}
+}
+
+func TestNextWakeIncludesDigest(t *testing.T) {
+	store, err := rules.NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	svc := &Service{pollPeriod: time.Hour, ruleStore: store, cfg: config.Config{Timezone: "UTC", Digest: config.DigestConfig{Schedule: "0 8 * * *"}}}
+	lastPoll := time.Date(2024, time.March, 14, 7, 30, 0, 0, time.UTC)
+	if err := store.StartDigest(lastPoll.Add(-time.Hour)); err != nil {
+		t.Fatalf("start digest: %v", err)
+	}
+	wake, scheduled := svc.nextWake(lastPoll, lastPoll.Add(time.Minute))
+	if want := time.Date(2024, time.March, 14, 8, 0, 0, 0, time.UTC); !scheduled || !wake.Equal(want) {
+		t.Fatalf("expected digest wake at %s, got %s scheduled=%v", want, wake, scheduled)
+	}
+}
+
+func TestDigestKeepsLocalTimeAcrossDST(t *testing.T) {
+	svc := &Service{cfg: config.Config{Timezone: "America/New_York", Digest: config.DigestConfig{Schedule: "0 9 * * *"}}}
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	// The stored time comes back in UTC; the day after is the spring-forward change.
+	last := time.Date(2024, time.March, 9, 9, 0, 0, 0, ny).UTC()
+	next, err := svc.digestAfter(last)
+	if err != nil {
+		t.Fatalf("digestAfter: %v", err)
+	}
+	want := time.Date(2024, time.March, 10, 9, 0, 0, 0, ny)
+	if !next.Equal(want) {
+		t.Fatalf("expected next digest %s, got %s", want, next)
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"ynab-alerts/internal/notifier"
+	"ynab-alerts/internal/rules"
+)
+
+// escalate sends the escalation steps that have come due for every open,
+// unacknowledged alert, whether or not its rule fired again this tick. Each
+// step is sent once; acknowledging the alert stops further steps. Steps wait
+// while the rule is snoozed or muted and while its own delivery is held.
+func (s *Service) escalate(ctx context.Context, ruleDefs []rules.Rule, now time.Time) {
+	if s.ruleStore == nil {
+		return
+	}
+	alerts := s.ruleStore.Alerts()
+	for _, rule := range ruleDefs {
+		policy := strings.TrimSpace(rule.Escalation)
+		state, open := alerts[rule.Name]
+		if policy == "" || !open || state.Acknowledged || s.holdDelivery(rule, now) {
+			continue
+		}
+		if _, silenced := s.ruleStore.SilencedAt(rule.Name, now); silenced {
+			continue
+		}
+		steps, ok := s.cfg.Escalation[policy]
+		if !ok {
+			log.Printf("rule %s references unknown escalation policy %q", rule.Name, policy)
+			continue
+		}
+		alert := s.lastAlert(rule, state, now)
+		for i := state.Escalations; i < len(steps); i++ {
+			step := steps[i]
+			if now.Sub(state.FiredAt) < step.After {
+				break
+			}
+			esc := alert
+			esc.Subject = fmt.Sprintf("%s (unacknowledged for %s)", alert.Subject, step.After)
+			s.debugf("escalating %s to %v (policy %s step %d)", rule.Name, step.Notify, policy, i+1)
+			if err := s.deliver(ctx, esc, step.Notify); err != nil {
+				log.Printf("escalation failed for %s: %v", rule.Name, err)
+			}
+			if err := s.ruleStore.MarkEscalated(rule.Name, i+1); err != nil {
+				log.Printf("alert state update failed for %s: %v", rule.Name, err)
+			}
+		}
+	}
+}
+
+// lastAlert returns the alert most recently built for rule, or a summary of
+// its alert state when none was built since the daemon started.
+func (s *Service) lastAlert(rule rules.Rule, state rules.AlertState, now time.Time) notifier.Alert {
+	s.mu.Lock()
+	alert, ok := s.alerts[rule.Name]
+	s.mu.Unlock()
+	if ok {
+		return alert
+	}
+	return notifier.Alert{
+		Rule:     rule.Name,
+		Subject:  rule.Name,
+		Message:  fmt.Sprintf("firing since %s", state.FiredAt.Format(time.RFC3339)),
+		Severity: rule.Level(),
+		Budget:   s.cfg.BudgetID,
+		Source:   rule.Pos.String(),
+		Time:     now,
+		Pushover: pushoverParams(rule.Pushover, rule.Level()),
+	}
+}
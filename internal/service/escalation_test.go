@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ynab-alerts/internal/config"
+	"ynab-alerts/internal/notifier"
+	"ynab-alerts/internal/rules"
+)
+
+func TestEscalateSendsDueStepsOnce(t *testing.T) {
+	store, err := rules.NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	capture := &capturingNotifier{}
+	svc := &Service{
+		cfg: config.Config{Escalation: map[string][]config.EscalationStep{
+			"overdraft": {
+				{After: 15 * time.Minute, Notify: []string{"pushover"}},
+				{After: time.Hour, Notify: []string{"exec"}},
+			},
+		}},
+		notifier:  capture,
+		ruleStore: store,
+	}
+	rule := rules.Rule{Name: "overdraft", Escalation: "overdraft"}
+	ruleDefs := []rules.Rule{rule}
+	svc.recordAlert(notifier.Alert{Rule: rule.Name, Subject: rule.Name, Message: "low"})
+	ctx := context.Background()
+	fired := time.Date(2024, time.March, 14, 9, 0, 0, 0, time.UTC)
+	if _, err := store.MarkFiring(rule.Name, fired); err != nil {
+		t.Fatalf("mark firing: %v", err)
+	}
+
+	svc.escalate(ctx, ruleDefs, fired.Add(10*time.Minute))
+	if len(capture.alerts) != 0 {
+		t.Fatalf("expected no escalation before the first step, got %d", len(capture.alerts))
+	}
+	svc.escalate(ctx, ruleDefs, fired.Add(20*time.Minute))
+	svc.escalate(ctx, ruleDefs, fired.Add(30*time.Minute))
+	if len(capture.alerts) != 1 || !strings.Contains(capture.alerts[0].Subject, "unacknowledged") {
+		t.Fatalf("expected one escalation, got %+v", capture.alerts)
+	}
+
+	if _, err := store.Acknowledge(rule.Name, "phone", fired.Add(40*time.Minute)); err != nil {
+		t.Fatalf("acknowledge: %v", err)
+	}
+	svc.escalate(ctx, ruleDefs, fired.Add(2*time.Hour))
+	if len(capture.alerts) != 1 {
+		t.Fatalf("expected acknowledgement to stop escalation, got %d alerts", len(capture.alerts))
+	}
+}
+
+func TestEscalateWaitsOutSnooze(t *testing.T) {
+	store, err := rules.NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	capture := &capturingNotifier{}
+	svc := &Service{
+		cfg: config.Config{Escalation: map[string][]config.EscalationStep{
+			"overdraft": {{After: 15 * time.Minute, Notify: []string{"pushover"}}},
+		}},
+		notifier:  capture,
+		ruleStore: store,
+	}
+	rule := rules.Rule{Name: "overdraft", Escalation: "overdraft"}
+	ctx := context.Background()
+	fired := time.Date(2024, time.March, 14, 9, 0, 0, 0, time.UTC)
+	if _, err := store.MarkFiring(rule.Name, fired); err != nil {
+		t.Fatalf("mark firing: %v", err)
+	}
+	snoozeEnd := fired.Add(time.Hour)
+	if err := store.Silence(rule.Name, rules.Silence{Until: snoozeEnd, CreatedAt: fired}); err != nil {
+		t.Fatalf("snooze: %v", err)
+	}
+
+	svc.escalate(ctx, []rules.Rule{rule}, fired.Add(30*time.Minute))
+	if len(capture.alerts) != 0 {
+		t.Fatalf("expected no escalation while snoozed, got %+v", capture.alerts)
+	}
+	svc.escalate(ctx, []rules.Rule{rule}, snoozeEnd.Add(time.Minute))
+	if len(capture.alerts) != 1 {
+		t.Fatalf("expected the due step once the snooze ends, got %d", len(capture.alerts))
+	}
+}
+
+func TestEscalateWithoutRefiring(t *testing.T) {
+	store, err := rules.NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	capture := &capturingNotifier{}
+	svc := &Service{
+		cfg: config.Config{Escalation: map[string][]config.EscalationStep{
+			"overdraft": {{After: 15 * time.Minute, Notify: []string{"pushover"}}},
+		}},
+		notifier:  capture,
+		ruleStore: store,
+	}
+	fired := time.Date(2024, time.March, 14, 9, 0, 0, 0, time.UTC)
+	if _, err := store.MarkFiring("overdraft", fired); err != nil {
+		t.Fatalf("mark firing: %v", err)
+	}
+
+	// e.g. a scheduled rule that fired once and has not been evaluated since
+	svc.escalate(context.Background(), []rules.Rule{{Name: "overdraft", Escalation: "overdraft"}}, fired.Add(20*time.Minute))
+	if len(capture.alerts) != 1 || !strings.Contains(capture.alerts[0].Message, "firing since") {
+		t.Fatalf("expected escalation from alert state alone, got %+v", capture.alerts)
+	}
+}
+
+func TestAckHandler(t *testing.T) {
+	store, err := rules.NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	if _, err := store.MarkFiring("low", time.Now()); err != nil {
+		t.Fatalf("mark firing: %v", err)
+	}
+	svc := &Service{ruleStore: store}
+	ack := func(rule string) int {
+		form := url.Values{"rule": {rule}, "by": {"ops"}}
+		req := httptest.NewRequest(http.MethodPost, "/ack", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		svc.Handler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := ack("low"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if a, _ := store.Alert("low"); !a.Acknowledged || a.AcknowledgedBy != "ops" {
+		t.Fatalf("expected alert acknowledged by ops, got %+v", a)
+	}
+	if code := ack("missing"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for inactive rule, got %d", code)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"ynab-alerts/internal/config"
@@ -20,6 +23,16 @@ type Service struct {
 	ruleStore  *rules.Store
 	ruleDir    string
 	pollPeriod time.Duration
+	programs   *rules.ProgramCache // compiled expressions reused across reloads
+
+	calendar      *rules.Calendar // holiday calendar, see loadCalendar
+	calendarStamp string
+
+	mu        sync.Mutex
+	lastRules []rules.Rule
+	lastRes   rules.Result
+	lastTick  time.Time
+	alerts    map[string]notifier.Alert // last alert built per rule, for escalations
 }
 
 // New builds a Service.
@@ -31,37 +44,77 @@ func New(cfg config.Config, ynabClient *ynab.Client, notify notifier.Notifier, s
 		ruleStore:  store,
 		ruleDir:    cfg.RulesDir,
 		pollPeriod: cfg.PollInterval,
+		programs:   rules.NewProgramCache(),
 	}
 }
 
-// Run starts the polling loop until context cancellation.
+// Run polls every PollInterval until context cancellation, waking in
+// between for rule schedules that activate before the next poll.
 func (s *Service) Run(ctx context.Context) error {
-	ticker := time.NewTicker(s.pollPeriod)
-	defer ticker.Stop()
-
 	// trigger immediately on startup
 	s.debugf("starting daemon with poll interval %s", s.pollPeriod)
-	if err := s.tick(ctx); err != nil {
+	lastPoll := time.Now()
+	if err := s.tick(ctx, false); err != nil {
 		log.Printf("initial tick error: %v", err)
 	}
 
 	for {
+		now := time.Now()
+		wake, scheduled := s.nextWake(lastPoll, now)
+		timer := time.NewTimer(wake.Sub(now))
 		select {
 		case <-ctx.Done():
+			timer.Stop()
 			return ctx.Err()
-		case <-ticker.C:
-			if err := s.tick(ctx); err != nil {
-				log.Printf("tick error: %v", err)
-			}
+		case <-timer.C:
+		}
+		if !scheduled {
+			lastPoll = wake
+		}
+		if err := s.tick(ctx, scheduled); err != nil {
+			log.Printf("tick error: %v", err)
 		}
 	}
 }
 
-func (s *Service) tick(ctx context.Context) error {
-	now := time.Now()
-	if !s.withinEvalWindow(now) {
-		s.debugf("skipping evaluation outside window (%s-%s)", s.windowStr())
-		return nil
+// nextWake returns when the loop should run next: the next poll, or an
+// earlier schedule activation of the last loaded rules or digest
+// (scheduled = true).
+func (s *Service) nextWake(lastPoll, now time.Time) (time.Time, bool) {
+	poll := lastPoll.Add(s.pollPeriod)
+	if poll.Before(now) {
+		poll = now
+	}
+	wake, scheduled := poll, false
+	s.mu.Lock()
+	ruleDefs := s.lastRules
+	s.mu.Unlock()
+	if next, ok := rules.NextRun(ruleDefs, now.In(s.cfg.Location())); ok && next.Before(wake) {
+		s.debugf("next schedule activation at %s", next.Format(time.RFC3339))
+		wake, scheduled = next, true
+	}
+	if next, ok := s.nextDigest(); ok && next.After(now) && next.Before(wake) {
+		s.debugf("next digest at %s", next.Format(time.RFC3339))
+		wake, scheduled = next, true
+	}
+	return wake, scheduled
+}
+
+// tick polls YNAB and evaluates the rules. A scheduled tick only evaluates
+// the cron clauses of scheduled rules, so rules and clauses that are already
+// firing are not notified again between polls.
+func (s *Service) tick(ctx context.Context, scheduled bool) error {
+	now := time.Now().In(s.cfg.Location())
+	if f, ok := s.notifier.(*notifier.Fanout); ok {
+		if err := f.Retry(ctx); err != nil {
+			log.Printf("retry queue error: %v", err)
+		}
+	}
+
+	if s.ruleStore != nil {
+		if err := s.ruleStore.Reload(); err != nil {
+			log.Printf("store reload error: %v", err)
+		}
 	}
 
 	s.debugf("fetching accounts for budget %s", s.cfg.BudgetID)
@@ -71,38 +124,260 @@ func (s *Service) tick(ctx context.Context) error {
 	}
 	accountBalances := ynab.BalanceMap(accounts)
 	s.debugf("loaded %d account balances", len(accountBalances))
+	if s.ruleStore != nil {
+		names := make([]string, 0, len(accounts))
+		for _, a := range accounts {
+			names = append(names, a.Name)
+		}
+		// lint checks account references against the names seen here
+		if err := s.ruleStore.SetAccounts(names); err != nil {
+			log.Printf("account cache update failed: %v", err)
+		}
+	}
 
-	ruleDefs, err := rules.LoadDir(s.ruleDir)
+	ruleDefs, err := rules.LoadDirWithCache(s.ruleDir, s.programs)
 	if err != nil {
 		return err
 	}
 	s.debugf("loaded %d rule(s)", len(ruleDefs))
 
+	cal, err := s.loadCalendar()
+	if err != nil {
+		return err
+	}
+
+	info := make(map[string]rules.AccountInfo, len(accounts))
+	for _, a := range accounts {
+		info[a.Name] = rules.AccountInfo{Type: a.Type, OnBudget: a.OnBudget, Closed: a.Closed, Deleted: a.Deleted}
+	}
 	data := rules.Data{
-		Accounts: accountBalances,
-		Vars:     map[string]int64{},
-		Now:      now,
+		Accounts:    accountBalances,
+		AccountInfo: info,
+		Vars:        map[string]int64{},
+		Now:         now,
+		Calendar:    cal,
 	}
 	if s.ruleStore != nil {
 		data.Vars = s.ruleStore.Snapshot()
 		s.debugf("preloaded %d observed variable(s)", len(data.Vars))
 	}
 
-	triggers, err := rules.Evaluate(ctx, ruleDefs, s.ruleStore, data)
+	evalDefs := ruleDefs
+	if scheduled {
+		evalDefs = nil
+		for _, r := range ruleDefs {
+			if r.Scheduled() {
+				evalDefs = append(evalDefs, r.ScheduledClauses())
+			}
+		}
+	}
+	res, err := rules.EvaluateRules(ctx, evalDefs, s.ruleStore, data)
 	if err != nil {
 		return err
 	}
+	triggers := res.Triggers
 
+	acked := s.trackAlerts(res, now)
 	for _, trig := range triggers {
-		s.debugf("notifying for rule %s: %s", trig.Rule.Name, trig.Message)
-		if err := s.notifier.Notify(ctx, trig.Rule.Name, trig.Message); err != nil {
+		channels, digest := s.route(trig)
+		if digest {
+			s.queueDigest(trig, now)
+		}
+		if len(channels) == 0 && digest {
+			continue
+		}
+		alert := notifier.Alert{
+			Rule:     trig.Rule.Name,
+			Subject:  trig.Rule.Name,
+			Message:  trig.Message,
+			Severity: trig.Severity,
+			Budget:   s.cfg.BudgetID,
+			Source:   trig.Pos().String(),
+			Time:     now,
+			Pushover: pushoverParams(trig.Rule.Pushover, trig.Severity),
+		}
+		s.recordAlert(alert)
+		if acked[trig.Rule.Name] && alert.Pushover != nil && alert.Pushover.Priority == 2 {
+			s.debugf("rule %s emergency alert acknowledged; not paging again", trig.Rule.Name)
+			continue
+		}
+		if s.holdDelivery(trig.Rule, now) {
+			s.deferAlert(alert, channels)
+			continue
+		}
+		s.debugf("notifying for rule %s (%s): %s", trig.Rule.Name, trig.Severity, trig.Message)
+		s.dropDeferred(trig.Rule.Name)
+		if err := s.deliver(ctx, alert, channels); err != nil {
 			log.Printf("notify failed for %s: %v", trig.Rule.Name, err)
 		}
 	}
-	log.Printf("evaluated %d rule(s); %d triggered", len(ruleDefs), len(triggers))
+	s.escalate(ctx, ruleDefs, now)
+	s.remember(ruleDefs, s.mergeResult(res, evalDefs), now)
+	s.releaseDeferred(ctx, res, ruleDefs, now)
+	s.publishStates(ctx, res)
+	s.maybeSendDigest(ctx, now, ruleDefs, accountBalances)
+	log.Printf("evaluated %d rule(s); %d triggered", len(evalDefs), len(triggers))
 	return nil
 }
 
+// loadCalendar returns the holiday calendar, re-reading the files only when
+// one of them has changed since the last load.
+func (s *Service) loadCalendar() (*rules.Calendar, error) {
+	if len(s.cfg.Holidays) == 0 {
+		return nil, nil
+	}
+	var stamp strings.Builder
+	for _, path := range s.cfg.Holidays {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&stamp, "%s\x00%d\x00%d\x00", path, info.ModTime().UnixNano(), info.Size())
+	}
+	if s.calendar != nil && stamp.String() == s.calendarStamp {
+		return s.calendar, nil
+	}
+	cal, err := rules.LoadCalendar(s.cfg.Holidays...)
+	if err != nil {
+		return nil, err
+	}
+	s.debugf("loaded %d holiday(s)", cal.Len())
+	s.calendar, s.calendarStamp = cal, stamp.String()
+	return cal, nil
+}
+
+// deliver routes alert to the rule's notify channels when fanning out, or to
+// the single configured notifier otherwise.
+func (s *Service) deliver(ctx context.Context, alert notifier.Alert, channels []string) error {
+	if f, ok := s.notifier.(*notifier.Fanout); ok {
+		return f.Deliver(ctx, alert, f.Resolve(channels))
+	}
+	return notifier.Send(ctx, s.notifier, alert)
+}
+
+func (s *Service) recordAlert(alert notifier.Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.alerts == nil {
+		s.alerts = map[string]notifier.Alert{}
+	}
+	s.alerts[alert.Rule] = alert
+}
+
+// trackAlerts records firing rules in the store and clears alerts for rules
+// that were checked and no longer fire. It returns the firing rules whose
+// alert has been acknowledged.
+func (s *Service) trackAlerts(res rules.Result, now time.Time) map[string]bool {
+	if s.ruleStore == nil {
+		return nil
+	}
+	acked := map[string]bool{}
+	firing := make(map[string]bool, len(res.Triggers))
+	for _, trig := range res.Triggers {
+		name := trig.Rule.Name
+		if firing[name] {
+			continue
+		}
+		firing[name] = true
+		state, err := s.ruleStore.MarkFiring(name, now)
+		if err != nil {
+			log.Printf("alert state update failed for %s: %v", name, err)
+		}
+		acked[name] = state.Acknowledged
+	}
+	for _, name := range res.Checked {
+		if firing[name] {
+			continue
+		}
+		if err := s.ruleStore.ClearAlert(name); err != nil {
+			log.Printf("alert state update failed for %s: %v", name, err)
+		}
+		s.mu.Lock()
+		delete(s.alerts, name)
+		s.mu.Unlock()
+	}
+	return acked
+}
+
+// route picks the notifier channels for a trigger and whether it joins the
+// digest. A rule with delivery: digest always goes to the digest only, since
+// it opted out of pushes. Otherwise a routing entry for the trigger's
+// severity replaces the rule's own notify list; the pseudo-channel "digest"
+// batches it into the summary.
+func (s *Service) route(trig rules.Trigger) ([]string, bool) {
+	if trig.Rule.IsDigest() {
+		return nil, true
+	}
+	routed, ok := s.cfg.Routing[trig.Severity]
+	if !ok {
+		return trig.Rule.Notify, false
+	}
+	var channels []string
+	digest := false
+	for _, name := range routed {
+		if strings.EqualFold(strings.TrimSpace(name), rules.DeliveryDigest) {
+			digest = true
+			continue
+		}
+		channels = append(channels, name)
+	}
+	return channels, digest
+}
+
+// severityPriority maps a severity to the Pushover priority used when a rule
+// does not set one explicitly.
+func severityPriority(severity string) int {
+	switch severity {
+	case rules.SeverityCritical:
+		return 2
+	case rules.SeverityInfo:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func pushoverParams(opts *rules.PushoverOptions, severity string) *notifier.PushoverParams {
+	if opts == nil {
+		if p := severityPriority(severity); p != 0 {
+			return &notifier.PushoverParams{Priority: p}
+		}
+		return nil
+	}
+	params := &notifier.PushoverParams{
+		Priority: severityPriority(severity),
+		Retry:    time.Duration(opts.Retry),
+		Expire:   time.Duration(opts.Expire),
+		Sound:    opts.Sound,
+		URL:      opts.URL,
+		URLTitle: opts.URLTitle,
+		HTML:     opts.HTML,
+		TTL:      time.Duration(opts.TTL),
+	}
+	if opts.Priority != nil {
+		params.Priority = *opts.Priority
+	}
+	return params
+}
+
+// publishStates reports firing/ok for every rule that was checked this tick
+// to notifiers that track per-rule state.
+func (s *Service) publishStates(ctx context.Context, res rules.Result) {
+	sn, ok := s.notifier.(notifier.StateNotifier)
+	if !ok {
+		return
+	}
+	firing := make(map[string]bool, len(res.Triggers))
+	for _, trig := range res.Triggers {
+		firing[trig.Rule.Name] = true
+	}
+	for _, name := range res.Checked {
+		if err := sn.NotifyState(ctx, name, firing[name]); err != nil {
+			log.Printf("state publish failed for %s: %v", name, err)
+		}
+	}
+}
+
 func (s *Service) debugf(format string, args ...interface{}) {
 	if !s.cfg.Debug {
 		return
@@ -110,7 +385,65 @@ func (s *Service) debugf(format string, args ...interface{}) {
 	log.Printf("[debug] "+format, args...)
 }
 
-func (s *Service) withinEvalWindow(now time.Time) bool {
+// holdDelivery reports whether a rule's alert should be deferred at now,
+// either because it is outside the global day window or inside the rule's
+// own quiet hours. Per-channel quiet hours are applied by the fan-out.
+func (s *Service) holdDelivery(rule rules.Rule, now time.Time) bool {
+	return !s.withinDeliveryWindow(now) || rule.InQuietHours(now)
+}
+
+func (s *Service) deferAlert(alert notifier.Alert, channels []string) {
+	f, ok := s.notifier.(*notifier.Fanout)
+	if !ok {
+		log.Printf("quiet hours need a fan-out notifier; dropping alert for %s", alert.Rule)
+		return
+	}
+	s.debugf("deferring alert for %s (delivery window %s)", alert.Rule, s.windowStr())
+	if err := f.Defer(alert, f.Resolve(channels)); err != nil {
+		log.Printf("defer failed for %s: %v", alert.Rule, err)
+	}
+}
+
+func (s *Service) dropDeferred(rule string) {
+	if f, ok := s.notifier.(*notifier.Fanout); ok {
+		if err := f.DropDeferred(rule); err != nil {
+			log.Printf("dropping deferred alerts for %s failed: %v", rule, err)
+		}
+	}
+}
+
+// releaseDeferred collapses deferred alerts for rules that were checked this
+// tick and no longer fire or are silenced, then sends the rest whose windows have opened.
+func (s *Service) releaseDeferred(ctx context.Context, res rules.Result, ruleDefs []rules.Rule, now time.Time) {
+	f, ok := s.notifier.(*notifier.Fanout)
+	if !ok {
+		return
+	}
+	firing := make(map[string]bool, len(res.Triggers))
+	for _, trig := range res.Triggers {
+		firing[trig.Rule.Name] = true
+	}
+	for _, name := range res.Checked {
+		if !firing[name] {
+			s.dropDeferred(name)
+		}
+	}
+	for _, name := range res.Silenced {
+		s.dropDeferred(name)
+	}
+	byName := make(map[string]rules.Rule, len(ruleDefs))
+	for _, r := range ruleDefs {
+		byName[r.Name] = r
+	}
+	err := f.ReleaseDeferred(ctx, func(q notifier.QueuedAlert) bool {
+		return !s.holdDelivery(byName[q.Alert.Rule], now)
+	})
+	if err != nil {
+		log.Printf("releasing deferred alerts: %v", err)
+	}
+}
+
+func (s *Service) withinDeliveryWindow(now time.Time) bool {
 	// No window configured.
 	if s.cfg.DayStart == 0 && s.cfg.DayEnd == 0 {
 		return true
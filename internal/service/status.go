@@ -0,0 +1,186 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ynab-alerts/internal/rules"
+)
+
+// Rule states reported by the status endpoint.
+const (
+	stateFiring  = "firing"
+	stateOK      = "ok"
+	stateUnknown = "unknown" // not checked on the last tick
+	stateSnoozed = "snoozed"
+	stateMuted   = "muted"
+)
+
+// RuleStatus is one rule's entry in the status report.
+type RuleStatus struct {
+	Name          string     `json:"name"`
+	Severity      string     `json:"severity"`
+	State         string     `json:"state"`
+	FiredAt       *time.Time `json:"fired_at,omitempty"`
+	Acknowledged  bool       `json:"acknowledged,omitempty"`
+	Escalations   int        `json:"escalations,omitempty"`
+	SnoozedUntil  *time.Time `json:"snoozed_until,omitempty"`
+	SilenceReason string     `json:"silence_reason,omitempty"`
+}
+
+// Status is the daemon state served at /status.
+type Status struct {
+	LastTick time.Time    `json:"last_tick"`
+	Rules    []RuleStatus `json:"rules"`
+}
+
+// remember keeps the latest tick's rules and result for status reporting.
+func (s *Service) remember(ruleDefs []rules.Rule, res rules.Result, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastRules = ruleDefs
+	s.lastRes = res
+	s.lastTick = now
+}
+
+// mergeResult combines a tick's result with the remembered result of the
+// last tick for the rules it says nothing about: rules outside evaluated, and
+// scheduled rules none of whose clauses were due.
+func (s *Service) mergeResult(res rules.Result, evaluated []rules.Rule) rules.Result {
+	s.mu.Lock()
+	prev := s.lastRes
+	s.mu.Unlock()
+
+	fresh := make(map[string]bool, len(evaluated))
+	for _, r := range evaluated {
+		if !r.Scheduled() {
+			fresh[r.Name] = true
+		}
+	}
+	for _, trig := range res.Triggers {
+		fresh[trig.Rule.Name] = true
+	}
+	for _, name := range append(append([]string(nil), res.Checked...), res.Silenced...) {
+		fresh[name] = true
+	}
+	merged := res
+	merged.Triggers = append([]rules.Trigger(nil), res.Triggers...)
+	merged.Checked = append([]string(nil), res.Checked...)
+	merged.Silenced = append([]string(nil), res.Silenced...)
+	for _, trig := range prev.Triggers {
+		if !fresh[trig.Rule.Name] {
+			merged.Triggers = append(merged.Triggers, trig)
+		}
+	}
+	for _, name := range prev.Checked {
+		if !fresh[name] {
+			merged.Checked = append(merged.Checked, name)
+		}
+	}
+	for _, name := range prev.Silenced {
+		if !fresh[name] {
+			merged.Silenced = append(merged.Silenced, name)
+		}
+	}
+	return merged
+}
+
+// Status reports each rule from the last tick with its severity and state.
+func (s *Service) Status() Status {
+	s.mu.Lock()
+	ruleDefs, res, tick := s.lastRules, s.lastRes, s.lastTick
+	s.mu.Unlock()
+
+	firing := make(map[string]bool, len(res.Triggers))
+	for _, trig := range res.Triggers {
+		firing[trig.Rule.Name] = true
+	}
+	checked := make(map[string]bool, len(res.Checked))
+	for _, name := range res.Checked {
+		checked[name] = true
+	}
+
+	st := Status{LastTick: tick, Rules: []RuleStatus{}}
+	for _, r := range ruleDefs {
+		rs := RuleStatus{Name: r.Name, Severity: r.Level(), State: stateUnknown}
+		switch {
+		case firing[r.Name]:
+			rs.State = stateFiring
+		case checked[r.Name]:
+			rs.State = stateOK
+		}
+		if s.ruleStore != nil {
+			if a, ok := s.ruleStore.Alert(r.Name); ok {
+				fired := a.FiredAt
+				rs.FiredAt = &fired
+				rs.Acknowledged = a.Acknowledged
+				rs.Escalations = a.Escalations
+			}
+			if sil, ok := s.ruleStore.SilencedAt(r.Name, time.Now()); ok {
+				rs.State = stateSnoozed
+				if sil.Muted() {
+					rs.State = stateMuted
+				} else {
+					until := sil.Until
+					rs.SnoozedUntil = &until
+				}
+				rs.SilenceReason = sil.Reason
+			}
+		}
+		st.Rules = append(st.Rules, rs)
+	}
+	return st
+}
+
+// Handler serves the daemon's HTTP endpoints.
+func (s *Service) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/ack", s.handleAck)
+	return mux
+}
+
+func (s *Service) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.Status())
+}
+
+// handleAck acknowledges a rule's active alert: POST /ack with form values
+// rule and, optionally, by.
+func (s *Service) handleAck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rule := strings.TrimSpace(r.FormValue("rule"))
+	if rule == "" {
+		http.Error(w, "rule is required", http.StatusBadRequest)
+		return
+	}
+	if s.ruleStore == nil {
+		http.Error(w, "no alert store", http.StatusServiceUnavailable)
+		return
+	}
+	by := strings.TrimSpace(r.FormValue("by"))
+	if by == "" {
+		by = "http"
+	}
+	ok, err := s.ruleStore.Acknowledge(rule, by, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, fmt.Sprintf("no active alert for %s", rule), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"rule": rule, "acknowledged": true})
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ynab-alerts/internal/config"
+	"ynab-alerts/internal/rules"
+)
+
+func TestRouteBySeverity(t *testing.T) {
+	svc := &Service{cfg: config.Config{Routing: map[string][]string{
+		"critical": {"pushover", "exec"},
+		"info":     {"digest"},
+	}}}
+	rule := rules.Rule{Name: "r", Notify: []string{"log"}}
+
+	channels, digest := svc.route(rules.Trigger{Rule: rule, Severity: rules.SeverityCritical})
+	if len(channels) != 2 || digest {
+		t.Fatalf("critical: got %v digest=%v", channels, digest)
+	}
+	channels, digest = svc.route(rules.Trigger{Rule: rule, Severity: rules.SeverityInfo})
+	if len(channels) != 0 || !digest {
+		t.Fatalf("info: got %v digest=%v", channels, digest)
+	}
+	channels, digest = svc.route(rules.Trigger{Rule: rule, Severity: rules.SeverityWarning})
+	if len(channels) != 1 || channels[0] != "log" || digest {
+		t.Fatalf("warning should use the rule's notify list, got %v digest=%v", channels, digest)
+	}
+	rule.Delivery = rules.DeliveryDigest
+	channels, digest = svc.route(rules.Trigger{Rule: rule, Severity: rules.SeverityCritical})
+	if len(channels) != 0 || !digest {
+		t.Fatalf("a digest rule should stay in the digest despite routing, got %v digest=%v", channels, digest)
+	}
+}
+
+func TestPushoverPriorityFromSeverity(t *testing.T) {
+	if p := pushoverParams(nil, rules.SeverityCritical); p == nil || p.Priority != 2 {
+		t.Fatalf("expected emergency priority for critical, got %+v", p)
+	}
+	if p := pushoverParams(nil, rules.SeverityWarning); p != nil {
+		t.Fatalf("expected no params for warning, got %+v", p)
+	}
+	low := -2
+	p := pushoverParams(&rules.PushoverOptions{Priority: &low}, rules.SeverityCritical)
+	if p.Priority != -2 {
+		t.Fatalf("explicit priority should win, got %d", p.Priority)
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	store, err := rules.NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	now := time.Date(2024, time.March, 14, 9, 0, 0, 0, time.UTC)
+	if _, err := store.MarkFiring("low", now); err != nil {
+		t.Fatalf("mark firing: %v", err)
+	}
+	svc := &Service{ruleStore: store}
+	low := rules.Rule{Name: "low", Severity: "critical"}
+	svc.remember([]rules.Rule{low, {Name: "ok"}, {Name: "later"}}, rules.Result{
+		Triggers: []rules.Trigger{{Rule: low}},
+		Checked:  []string{"low", "ok"},
+	}, now)
+
+	rec := httptest.NewRecorder()
+	svc.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	var st Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(st.Rules) != 3 {
+		t.Fatalf("expected 3 rules, got %+v", st.Rules)
+	}
+	if r := st.Rules[0]; r.State != "firing" || r.Severity != "critical" || r.FiredAt == nil {
+		t.Fatalf("unexpected firing rule status: %+v", r)
+	}
+	if r := st.Rules[1]; r.State != "ok" || r.Severity != "warning" {
+		t.Fatalf("unexpected ok rule status: %+v", r)
+	}
+	if st.Rules[2].State != "unknown" {
+		t.Fatalf("unchecked rule should be unknown, got %+v", st.Rules[2])
+	}
+}
+
+func TestStatusShowsSilences(t *testing.T) {
+	store, err := rules.NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	until := time.Now().Add(72 * time.Hour)
+	if err := store.Silence("dip", rules.Silence{Until: until, Reason: "until payday", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("silence: %v", err)
+	}
+	if err := store.Silence("noisy", rules.Silence{CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("silence: %v", err)
+	}
+	svc := &Service{ruleStore: store}
+	svc.remember([]rules.Rule{{Name: "dip"}, {Name: "noisy"}}, rules.Result{Silenced: []string{"dip", "noisy"}}, time.Now())
+
+	st := svc.Status()
+	if r := st.Rules[0]; r.State != "snoozed" || r.SnoozedUntil == nil || r.SilenceReason != "until payday" {
+		t.Fatalf("unexpected snoozed status: %+v", r)
+	}
+	if r := st.Rules[1]; r.State != "muted" || r.SnoozedUntil != nil {
+		t.Fatalf("unexpected muted status: %+v", r)
+	}
+}
+
+func TestStatusKeepsScheduledRulesBetweenActivations(t *testing.T) {
+	nine := rules.Rule{Name: "nine", When: rules.WhenList{{Schedule: "0 9 * * *", Condition: "true"}}}
+	polled := rules.Rule{Name: "polled", When: rules.WhenList{{Condition: "true"}}}
+	ruleDefs := []rules.Rule{nine, polled}
+	svc := &Service{}
+
+	// the scheduled tick fires the rule
+	fired := rules.Result{Triggers: []rules.Trigger{{Rule: nine}}, Checked: []string{"nine"}}
+	svc.remember(ruleDefs, svc.mergeResult(fired, []rules.Rule{nine}), time.Now())
+	// a poll tick where its schedule is not due
+	polledOnly := rules.Result{Checked: []string{"polled"}}
+	svc.remember(ruleDefs, svc.mergeResult(polledOnly, ruleDefs), time.Now())
+
+	st := svc.Status()
+	if st.Rules[0].State != "firing" || st.Rules[1].State != "ok" {
+		t.Fatalf("expected the scheduled rule to keep its last state, got %+v", st.Rules)
+	}
+}
+
+func TestStatusLeavesExpiredSnoozes(t *testing.T) {
+	store, err := rules.NewStore(t.TempDir() + "/obs.json")
+	if err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+	if err := store.Silence("dip", rules.Silence{Until: time.Now().Add(-time.Hour), CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("silence: %v", err)
+	}
+	svc := &Service{ruleStore: store}
+	svc.remember([]rules.Rule{{Name: "dip"}}, rules.Result{}, time.Now())
+
+	if r := svc.Status().Rules[0]; r.State == "snoozed" {
+		t.Fatalf("expired snooze reported: %+v", r)
+	}
+	if _, ok := store.Silences()["dip"]; !ok {
+		t.Fatalf("status should not prune expired snoozes")
+	}
+}
+
+func TestNextWake(t *testing.T) {
+	svc := &Service{pollPeriod: time.Hour, cfg: config.Config{Timezone: "UTC"}}
+	lastPoll := time.Date(2024, time.March, 14, 8, 30, 0, 0, time.UTC)
+	now := lastPoll.Add(time.Minute)
+
+	if wake, scheduled := svc.nextWake(lastPoll, now); scheduled || !wake.Equal(lastPoll.Add(time.Hour)) {
+		t.Fatalf("expected next poll, got %s scheduled=%v", wake, scheduled)
+	}
+	svc.lastRules = []rules.Rule{{Name: "nine", When: rules.WhenList{{Schedule: "0 9 * * *"}}}}
+	wake, scheduled := svc.nextWake(lastPoll, now)
+	if want := time.Date(2024, time.March, 14, 9, 0, 0, 0, time.UTC); !scheduled || !wake.Equal(want) {
+		t.Fatalf("expected schedule wake at %s, got %s scheduled=%v", want, wake, scheduled)
+	}
+}
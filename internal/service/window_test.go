@@ -1,13 +1,14 @@
 package service
 
 import (
+	"os"
 	"testing"
 	"time"
 
 	"ynab-alerts/internal/config"
 )
 
-func TestWithinEvalWindow(t *testing.T) {
+func TestWithinDeliveryWindow(t *testing.T) {
 	cfg := config.Config{
 		DayStart: 6 * time.Hour,
 		DayEnd:   22 * time.Hour,
@@ -27,8 +28,32 @@ func TestWithinEvalWindow(t *testing.T) {
 
 	for _, tt := range tc {
 		now := time.Date(2024, time.January, 1, tt.hour, tt.min, 0, 0, time.UTC)
-		if got := svc.withinEvalWindow(now); got != tt.expect {
+		if got := svc.withinDeliveryWindow(now); got != tt.expect {
 			t.Fatalf("hour %02d:%02d expected %v got %v", tt.hour, tt.min, tt.expect, got)
 		}
 	}
 }
+
+func TestLoadCalendarCachesUntilFilesChange(t *testing.T) {
+	path := t.TempDir() + "/holidays.yaml"
+	if err := os.WriteFile(path, []byte("- 2024-07-04\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	svc := &Service{cfg: config.Config{Holidays: []string{path}}}
+	first, err := svc.loadCalendar()
+	if err != nil || first.Len() != 1 {
+		t.Fatalf("load: %v (%v)", err, first)
+	}
+	if again, _ := svc.loadCalendar(); again != first {
+		t.Fatalf("expected unchanged calendar to be reused")
+	}
+
+	if err := os.WriteFile(path, []byte("- 2024-07-04\n- 2024-11-28\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if cal, err := svc.loadCalendar(); err != nil || cal.Len() != 2 {
+		t.Fatalf("expected changed calendar to be re-read, got %v (%v)", cal, err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package statefile
+
+// Lock is a no-op where advisory file locks are unavailable; updates are
+// then only serialized within a process.
+func Lock(string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,30 @@
+// Package statefile holds the helpers shared by the JSON state files the
+// daemon and CLI both update: the observation store and the outbox.
+package statefile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Write replaces path with data atomically, through a temporary file in the
+// same directory, so concurrent readers and a crash mid-write see either the
+// old or the new content.
+func Write(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -35,6 +35,8 @@ type Account struct {
 	Balance  int64  `json:"balance"`
 	Type     string `json:"type"`
 	OnBudget bool   `json:"on_budget"`
+	Closed   bool   `json:"closed"`
+	Deleted  bool   `json:"deleted"`
 }
 
 // Budget holds minimal budget info.